@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+//命令行模式，方便在脚本中使用：
+//  stuManagerSystem add --id 1 --name 张三 --class 火箭101 --scores 语文:90,数学:85 --enrolled 2020-09-01
+//  stuManagerSystem edit --id 1 --class 火箭102
+//  stuManagerSystem delete --id 1
+//  stuManagerSystem list --format table|json|csv
+//  stuManagerSystem serve --addr 127.0.0.1:9000
+//  stuManagerSystem import --file roster.csv [--dry-run]
+//  stuManagerSystem export [--file roster.csv] [--bom]
+//  stuManagerSystem history [1]
+
+func usage() {
+	fmt.Fprintln(flag.CommandLine.Output(), "usage: stuManagerSystem [-store json|mysql] [-data file] [-dsn dsn] [-history file] [-user name] [command] [flags]")
+	fmt.Fprintln(flag.CommandLine.Output(), "不带command时进入交互菜单，可用的command：add、edit、delete、list、serve、import、export、history")
+	flag.PrintDefaults()
+}
+
+//runCommand 执行args[0]指定的子命令，args[1:]为子命令的参数
+func runCommand(sm *studentMag, args []string) error {
+	switch args[0] {
+	case "add":
+		return addCmd(sm, args[1:])
+	case "edit":
+		return editCmd(sm, args[1:])
+	case "delete":
+		return deleteCmd(sm, args[1:])
+	case "list":
+		return listCmd(sm, args[1:], os.Stdout)
+	case "serve":
+		return serveCmd(sm, args[1:])
+	case "import":
+		return importCmd(sm, args[1:], os.Stdout)
+	case "export":
+		return exportCmd(sm, args[1:])
+	case "history":
+		return historyCmd(sm, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func addCmd(sm *studentMag, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	id := fs.Int("id", 0, "学员的id")
+	name := fs.String("name", "", "学员的name")
+	class := fs.String("class", "", "学员的class")
+	scores := fs.String("scores", "", "学员的成绩，格式：语文:90,数学:85")
+	enrolled := fs.String("enrolled", "", "学员的入学日期，格式：2006-01-02")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !isFlagSet(fs, "id") || *name == "" {
+		return errors.New("add: --id and --name are required")
+	}
+	newStu := newStudent(*id, *name, *class)
+	var err error
+	if newStu.scores, err = parseScores(*scores); err != nil {
+		return err
+	}
+	if newStu.enrolled, err = parseDate(*enrolled); err != nil {
+		return err
+	}
+	return sm.addStudent(newStu)
+}
+
+//editCmd 只修改命令行中指定了的字段，其余字段保持原值
+func editCmd(sm *studentMag, args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	id := fs.Int("id", 0, "要编辑的学员的id")
+	name := fs.String("name", "", "新的name")
+	class := fs.String("class", "", "新的class")
+	scores := fs.String("scores", "", "新的成绩，会整体替换原来的成绩，格式：语文:90,数学:85")
+	enrolled := fs.String("enrolled", "", "新的入学日期，格式：2006-01-02")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !isFlagSet(fs, "id") {
+		return errors.New("edit: --id is required")
+	}
+	old, err := sm.getStudent(*id)
+	if err != nil {
+		return err
+	}
+	newStu := old.clone()
+	if isFlagSet(fs, "name") {
+		newStu.name = *name
+	}
+	if isFlagSet(fs, "class") {
+		newStu.class = *class
+	}
+	if isFlagSet(fs, "scores") {
+		if newStu.scores, err = parseScores(*scores); err != nil {
+			return err
+		}
+	}
+	if isFlagSet(fs, "enrolled") {
+		if newStu.enrolled, err = parseDate(*enrolled); err != nil {
+			return err
+		}
+	}
+	return sm.editStudent(newStu)
+}
+
+func deleteCmd(sm *studentMag, args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	id := fs.Int("id", 0, "要删除的学员的id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !isFlagSet(fs, "id") {
+		return errors.New("delete: --id is required")
+	}
+	return sm.deleteStudent(*id)
+}
+
+func listCmd(sm *studentMag, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	format := fs.String("format", "table", "输出格式：table、json、csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	students := sm.listStudents()
+	switch *format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tCLASS\tENROLLED\tSCORES")
+		for _, v := range students {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", v.id, v.name, v.class, formatDate(v.enrolled), formatScores(v.scores))
+		}
+		return tw.Flush()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(students)
+	case "csv":
+		return sm.exportCSV(w, false)
+	default:
+		return fmt.Errorf("list: unknown format %q", *format)
+	}
+}
+
+func serveCmd(sm *studentMag, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:9000", "http服务监听的地址")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return serve(sm, *addr)
+}
+
+//importCmd 导入csv名单并输出导入结果
+func importCmd(sm *studentMag, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("file", "", "要导入的csv文件")
+	dryRun := fs.Bool("dry-run", false, "只校验并统计，不修改学员信息")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("import: --file is required")
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	report, err := sm.importCSV(f, *dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Fprint(w, "[dry-run] ")
+	}
+	fmt.Fprintln(w, report)
+	for _, re := range report.rejected {
+		fmt.Fprintf(w, "  rejected %v\n", re)
+	}
+	return nil
+}
+
+//exportCmd 导出csv名单，不指定--file时输出到标准输出
+func exportCmd(sm *studentMag, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fs.String("file", "", "导出的csv文件，默认输出到标准输出")
+	bom := fs.Bool("bom", false, "在文件开头写入BOM，方便Excel打开")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return sm.exportCSV(os.Stdout, *bom)
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if err := sm.exportCSV(f, *bom); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+//historyCmd 查看修改历史，学号可以直接跟在history后面：history 1，也可以用--id指定
+func historyCmd(sm *studentMag, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	id := fs.Int("id", 0, "学员的id，不指定时查看所有修改历史")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		if isFlagSet(fs, "id") {
+			return errors.New("id specified twice")
+		}
+		n, err := strconv.Atoi(fs.Arg(0))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid id %q", fs.Arg(0))
+		}
+		*id = n
+	default:
+		return fmt.Errorf("too many arguments: %v", fs.Args())
+	}
+	printHistory(sm.studentHistory(*id))
+	return nil
+}
+
+//isFlagSet 判断命令行中是否显式指定了某个flag
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
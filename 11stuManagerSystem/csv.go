@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//学员名单的csv格式：
+//第一行是表头，必须包含id和name列，class、enrolled列可选，
+//"score:科目"列是该科的成绩，其余列作为extra原样保存
+//更新已有学员时，文件中没有的列保持原值，有这一列但值为空的入学日期、成绩和extra会被清空
+//  id,name,class,enrolled,score:语文,score:数学,phone
+//  1,张三,火箭101,2020-09-01,90,85,13800000000
+
+//utf8Bom Excel导出的csv文件开头会带上BOM
+const utf8Bom = "\ufeff"
+
+//成绩列的表头前缀
+const scorePrefix = "score:"
+
+//rowError 导入时某一行的校验错误
+type rowError struct {
+	row int //在csv文件中是第几条记录，从1开始，表头是第1条
+	err error
+}
+
+func (re rowError) Error() string {
+	return fmt.Sprintf("row %d: %v", re.row, re.err)
+}
+
+//importReport 导入结果
+type importReport struct {
+	inserted  int
+	updated   int
+	unchanged int
+	rejected  []rowError
+}
+
+func (r *importReport) String() string {
+	return fmt.Sprintf("inserted:%d updated:%d unchanged:%d rejected:%d",
+		r.inserted, r.updated, r.unchanged, len(r.rejected))
+}
+
+//importCSV 从r中导入学员名单，学号已存在的更新，不存在的添加，校验不通过的行跳过
+//dryRun为true时只校验并统计，不修改学员信息
+func (sm *studentMag) importCSV(r io.Reader, dryRun bool) (*importReport, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty csv file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	cols, err := parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	//先读完并校验所有行，这时不需要加锁，输入很慢（比如标准输入）时也不会阻塞其他操作
+	report := &importReport{}
+	records := make([]*student, 0)
+	seen := make(map[int]int) //学号->第一次出现的记录序号，用来发现文件中重复的学号
+	row := 1                  //表头是第1条记录
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			//列数不对等解析错误只影响当前行，继续读下一行
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				report.rejected = append(report.rejected, rowError{row: row, err: pe.Err})
+				continue
+			}
+			return nil, err
+		}
+		stu, err := cols.toStudent(record)
+		if err != nil {
+			report.rejected = append(report.rejected, rowError{row: row, err: err})
+			continue
+		}
+		if first, ok := seen[stu.id]; ok {
+			report.rejected = append(report.rejected, rowError{row: row, err: fmt.Errorf("id:%d duplicated with row %d", stu.id, first)})
+			continue
+		}
+		seen[stu.id] = row
+		records = append(records, stu)
+	}
+
+	//只在和已有学员比较并保存时加锁，dryRun时不修改学员信息，加读锁就够了
+	if dryRun {
+		sm.rwlock.RLock()
+		defer sm.rwlock.RUnlock()
+	} else {
+		sm.rwlock.Lock()
+		defer sm.rwlock.Unlock()
+	}
+	inserts := make([]*student, 0)
+	updates := make([]*student, 0)
+	for _, stu := range records {
+		i := sm.indexOf(stu.id)
+		if i == -1 {
+			inserts = append(inserts, stu)
+			continue
+		}
+		stu = cols.merge(sm.students[i], stu)
+		if sm.students[i].equal(stu) {
+			report.unchanged++
+			continue
+		}
+		updates = append(updates, stu)
+	}
+	report.inserted = len(inserts)
+	report.updated = len(updates)
+	if dryRun || len(inserts)+len(updates) == 0 {
+		return report, nil
+	}
+
+	//所有行都处理完后只保存一次，整个导入作为一组修改，可以一次撤销
+	changes := make([]*change, 0, len(inserts)+len(updates))
+	for _, stu := range inserts {
+		c, err := sm.add(stu)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	for _, stu := range updates {
+		c, err := sm.edit(stu)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	if err := sm.commit("import", changes); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+//exportCSV 把所有学员导出为csv，成绩列和extra列分别按列名排序后依次放在enrolled之后
+//bom为true时在文件开头写入BOM，方便Excel正确识别中文
+func (sm *studentMag) exportCSV(w io.Writer, bom bool) error {
+	students := sm.listStudents()
+	subjects := make([]string, 0)
+	extraCols := make([]string, 0)
+	seenSubject := make(map[string]bool)
+	seenExtra := make(map[string]bool)
+	for _, s := range students {
+		for k := range s.scores {
+			if !seenSubject[k] {
+				seenSubject[k] = true
+				subjects = append(subjects, k)
+			}
+		}
+		for k := range s.extra {
+			if !seenExtra[k] {
+				seenExtra[k] = true
+				extraCols = append(extraCols, k)
+			}
+		}
+	}
+	sort.Strings(subjects)
+	sort.Strings(extraCols)
+
+	if bom {
+		if _, err := io.WriteString(w, utf8Bom); err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	header := []string{"id", "name", "class", "enrolled"}
+	for _, k := range subjects {
+		header = append(header, scorePrefix+k)
+	}
+	cw.Write(append(header, extraCols...))
+	for _, s := range students {
+		record := []string{strconv.Itoa(s.id), s.name, s.class, formatDate(s.enrolled)}
+		for _, k := range subjects {
+			v, ok := s.scores[k]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		for _, k := range extraCols {
+			record = append(record, s.extra[k])
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//csvColumns 表头中各列的位置
+type csvColumns struct {
+	id, name, class, enrolled int //class、enrolled列不存在时为-1
+	scores                    map[int]string
+	extra                     map[int]string
+}
+
+func parseHeader(header []string) (*csvColumns, error) {
+	cols := &csvColumns{id: -1, name: -1, class: -1, enrolled: -1, scores: make(map[int]string), extra: make(map[int]string)}
+	for i, h := range header {
+		if i == 0 {
+			h = strings.TrimPrefix(h, utf8Bom)
+		}
+		h = strings.TrimSpace(h)
+		switch strings.ToLower(h) {
+		case "id":
+			cols.id = i
+		case "name":
+			cols.name = i
+		case "class":
+			cols.class = i
+		case "enrolled":
+			cols.enrolled = i
+		case "", scorePrefix:
+			return nil, fmt.Errorf("header column %d is empty", i+1)
+		default:
+			if strings.HasPrefix(strings.ToLower(h), scorePrefix) {
+				subject := strings.TrimSpace(h[len(scorePrefix):])
+				if err := checkScores(map[string]float64{subject: 0}); err != nil {
+					return nil, fmt.Errorf("header column %d: %v", i+1, err)
+				}
+				cols.scores[i] = subject
+				continue
+			}
+			cols.extra[i] = h
+		}
+	}
+	if cols.id == -1 || cols.name == -1 {
+		return nil, errors.New("header must contain id and name columns")
+	}
+	return cols, nil
+}
+
+//toStudent 校验一行数据并转换为学员
+func (cols *csvColumns) toStudent(record []string) (*student, error) {
+	idStr := strings.TrimSpace(record[cols.id])
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %q", idStr)
+	}
+	name := strings.TrimSpace(record[cols.name])
+	if name == "" {
+		return nil, errors.New("name is empty")
+	}
+	stu := newStudent(id, name, "")
+	if cols.class != -1 {
+		stu.class = strings.TrimSpace(record[cols.class])
+	}
+	if cols.enrolled != -1 {
+		if stu.enrolled, err = parseDate(record[cols.enrolled]); err != nil {
+			return nil, err
+		}
+	}
+	for i, subject := range cols.scores {
+		v := strings.TrimSpace(record[i])
+		if v == "" {
+			continue
+		}
+		score, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			err = checkScores(map[string]float64{subject: score})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid score %q of %s", v, subject)
+		}
+		if stu.scores == nil {
+			stu.scores = make(map[string]float64, len(cols.scores))
+		}
+		stu.scores[subject] = score
+	}
+	for i, k := range cols.extra {
+		v := strings.TrimSpace(record[i])
+		if v == "" {
+			continue
+		}
+		if stu.extra == nil {
+			stu.extra = make(map[string]string, len(cols.extra))
+		}
+		stu.extra[k] = v
+	}
+	if err := stu.validate(); err != nil {
+		return nil, err
+	}
+	return stu, nil
+}
+
+//merge 更新已有学员时，文件中没有的列保持原值
+func (cols *csvColumns) merge(old, stu *student) *student {
+	merged := old.clone()
+	merged.name = stu.name
+	if cols.class != -1 {
+		merged.class = stu.class
+	}
+	if cols.enrolled != -1 {
+		merged.enrolled = stu.enrolled
+	}
+	for _, subject := range cols.scores {
+		if v, ok := stu.scores[subject]; ok {
+			if merged.scores == nil {
+				merged.scores = make(map[string]float64, len(stu.scores))
+			}
+			merged.scores[subject] = v
+		} else {
+			delete(merged.scores, subject)
+		}
+	}
+	for _, k := range cols.extra {
+		if v, ok := stu.extra[k]; ok {
+			if merged.extra == nil {
+				merged.extra = make(map[string]string, len(stu.extra))
+			}
+			merged.extra[k] = v
+		} else {
+			delete(merged.extra, k) //文件中有这一列但是值为空，表示清空
+		}
+	}
+	return merged
+}
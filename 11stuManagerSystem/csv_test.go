@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+//导入时读取输入很慢也不能阻塞其他操作
+func TestImportCSVSlowReader(t *testing.T) {
+	sm, err := newstudentMag(nil)
+	if err != nil {
+		t.Fatalf("newstudentMag failed, err:%v", err)
+	}
+	pr, pw := io.Pipe()
+	imported := make(chan error, 1)
+	go func() {
+		report, err := sm.importCSV(pr, false)
+		if err == nil && report.inserted != 2 {
+			t.Errorf("inserted = %d, want 2", report.inserted)
+		}
+		imported <- err
+	}()
+	if _, err := io.WriteString(pw, "id,name,class\n1,张三,火箭101\n"); err != nil {
+		t.Fatalf("write failed, err:%v", err)
+	}
+
+	//输入还没有结束，其他操作照常进行
+	added := make(chan error, 1)
+	go func() { added <- sm.addStudent(newStudent(2, "李四", "")) }()
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatalf("addStudent failed, err:%v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("addStudent blocked while importCSV was reading its input")
+	}
+
+	//文件中的学号2在导入时已经存在，按更新处理
+	io.WriteString(pw, "2,李四,火箭102\n3,王五,\n")
+	pw.Close()
+	if err := <-imported; err != nil {
+		t.Fatalf("importCSV failed, err:%v", err)
+	}
+	s, err := sm.getStudent(2)
+	if err != nil || s.class != "火箭102" {
+		t.Fatalf("getStudent(2) = %v, %v, want class 火箭102", s, err)
+	}
+}
@@ -1,3 +1,14 @@
 module github.com/Moqqll/02goLearning/11stuManagerSystem
 
 go 1.14
+
+require (
+	github.com/Moqqll/02goLearning/30db_mysql/sqlx v0.0.0
+	github.com/go-sql-driver/mysql v1.4.0
+	github.com/jmoiron/sqlx v1.2.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	google.golang.org/appengine v1.6.7 // indirect
+)
+
+//30db_mysql/sqlx是单独的module，使用本地的dbutil
+replace github.com/Moqqll/02goLearning/30db_mysql/sqlx => ../30db_mysql/sqlx
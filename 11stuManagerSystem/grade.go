@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//按总分排名时使用的科目名
+const totalSubject = "总分"
+
+//入学日期支持的格式，参考23stdlib_time中的时间格式化
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04",
+}
+
+//parseDate 按dateLayouts依次尝试解析入学日期，空字符串返回零值
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, want format like 2006-01-02", s)
+}
+
+//formatDate 入学日期统一格式化为2006-01-02，零值返回空字符串
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+//parseScores 解析"语文:90,数学:85"格式的成绩，空字符串返回nil
+func parseScores(s string) (map[string]float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	scores := make(map[string]float64)
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(item, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid score %q, want format like 语文:90", item)
+		}
+		subject := strings.TrimSpace(kv[0])
+		score, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid score %q, want format like 语文:90", item)
+		}
+		scores[subject] = score
+	}
+	if err := checkScores(scores); err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+//checkScores 科目名不能为空，分数不能为负数，也不能是NaN或无穷大（strconv.ParseFloat能解析出来，但是json中无法保存）
+//所有输入成绩的地方最后都会调用它
+func checkScores(scores map[string]float64) error {
+	for subject, score := range scores {
+		if subject == "" || subject == totalSubject {
+			return fmt.Errorf("invalid subject %q", subject)
+		}
+		if score < 0 || math.IsNaN(score) || math.IsInf(score, 0) {
+			return fmt.Errorf("invalid score %v of %s", score, subject)
+		}
+	}
+	return nil
+}
+
+//formatScores 按科目名排序后格式化为"数学:85,语文:90"
+func formatScores(scores map[string]float64) string {
+	subjects := make([]string, 0, len(scores))
+	for k := range scores {
+		subjects = append(subjects, k)
+	}
+	sort.Strings(subjects)
+	items := make([]string, 0, len(subjects))
+	for _, k := range subjects {
+		items = append(items, k+":"+strconv.FormatFloat(scores[k], 'f', -1, 64))
+	}
+	return strings.Join(items, ",")
+}
+
+//score 获取学员某一科的成绩，subject为totalSubject时返回总分
+func (s *student) score(subject string) (float64, bool) {
+	if subject != totalSubject {
+		v, ok := s.scores[subject]
+		return v, ok
+	}
+	if len(s.scores) == 0 {
+		return 0, false
+	}
+	var total float64
+	for _, v := range s.scores {
+		total += v
+	}
+	return total, true
+}
+
+//class 班级，参照10struct中的class结构体
+type class struct {
+	Title    string
+	Students []*student
+}
+
+//classes 按班级对学员分组，按班级名排序
+func (sm *studentMag) classes() []*class {
+	m := make(map[string]*class)
+	for _, s := range sm.listStudents() {
+		c, ok := m[s.class]
+		if !ok {
+			c = &class{Title: s.class, Students: make([]*student, 0)}
+			m[s.class] = c
+		}
+		c.Students = append(c.Students, s)
+	}
+	ret := make([]*class, 0, len(m))
+	for _, c := range m {
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Title < ret[j].Title })
+	return ret
+}
+
+//getClass 获取指定班级，班级中没有学员时返回错误
+func (sm *studentMag) getClass(title string) (*class, error) {
+	for _, c := range sm.classes() {
+		if c.Title == title {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("class:%s not found", title)
+}
+
+//subjects 班级中所有学员考过的科目，按科目名排序
+func (c *class) subjects() []string {
+	seen := make(map[string]bool)
+	for _, s := range c.Students {
+		for k := range s.scores {
+			seen[k] = true
+		}
+	}
+	ret := make([]string, 0, len(seen))
+	for k := range seen {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+//average 班级某一科的平均分，只统计有该科成绩的学员，n为统计的人数
+func (c *class) average(subject string) (avg float64, n int) {
+	var sum float64
+	for _, s := range c.Students {
+		if v, ok := s.score(subject); ok {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return sum / float64(n), n
+}
+
+//rankItem 排名中的一项，分数相同的名次相同
+type rankItem struct {
+	rank  int
+	score float64
+	stu   *student
+}
+
+//rank 按某一科的成绩从高到低排名，没有该科成绩的学员不参与排名
+func rank(students []*student, subject string) []rankItem {
+	items := make([]rankItem, 0, len(students))
+	for _, s := range students {
+		if v, ok := s.score(subject); ok {
+			items = append(items, rankItem{score: v, stu: s})
+		}
+	}
+	//分数相同时按学号排序，保证结果稳定
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].score != items[j].score {
+			return items[i].score > items[j].score
+		}
+		return items[i].stu.id < items[j].stu.id
+	})
+	for i := range items {
+		if i > 0 && items[i].score == items[i-1].score {
+			items[i].rank = items[i-1].rank
+		} else {
+			items[i].rank = i + 1
+		}
+	}
+	return items
+}
+
+//topN 取排名前n的学员，名次并列时一起返回
+func topN(students []*student, subject string, n int) ([]rankItem, error) {
+	if n <= 0 {
+		return nil, errors.New("n must be greater than 0")
+	}
+	items := rank(students, subject)
+	end := 0
+	for end < len(items) && items[end].rank <= n {
+		end++
+	}
+	return items[:end], nil
+}
+
+//showClassAverages 展示各班级的人数和各科平均分
+func (sm *studentMag) showClassAverages() {
+	for _, c := range sm.classes() {
+		fmt.Printf("班级:%s 人数:%d\n", c.Title, len(c.Students))
+		for _, subject := range append(c.subjects(), totalSubject) {
+			avg, n := c.average(subject)
+			if n == 0 {
+				continue
+			}
+			fmt.Printf("  %s 平均分:%.2f (%d人)\n", subject, avg, n)
+		}
+	}
+}
+
+//printRank 打印排名
+func printRank(items []rankItem, subject string) {
+	if len(items) == 0 {
+		fmt.Printf("没有学员有%s的成绩\n", subject)
+		return
+	}
+	for _, item := range items {
+		fmt.Printf("第%d名 id:%d name:%s class:%s %s:%s\n", item.rank, item.stu.id, item.stu.name, item.stu.class,
+			subject, strconv.FormatFloat(item.score, 'f', -1, 64))
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"time"
+)
+
+//修改历史：
+//每次添加、编辑、删除、导入都会产生一组change，按顺序追加写入修改历史文件，只追加不修改
+//撤销和重做只在当前会话中有效，撤销和重做本身也会记录到修改历史中
+
+//修改历史默认保存的文件
+const historyFile = "./students.history.jsonl"
+
+var (
+	errNothingToUndo = errors.New("nothing to undo")
+	errNothingToRedo = errors.New("nothing to redo")
+)
+
+//change 对一个学员的一次修改
+type change struct {
+	Seq  int       `json:"seq"`
+	Who  string    `json:"who"`
+	When time.Time `json:"when"`
+	Op   string    `json:"op"` //add、edit、delete、import、undo、redo
+	ID   int       `json:"id"`
+	Old  *student  `json:"old"`           //添加学员时为nil
+	New  *student  `json:"new"`           //删除学员时为nil
+	Ref  int       `json:"ref,omitempty"` //撤销和重做时对应的原修改的seq
+}
+
+//changeLog 修改历史，path为空时只保存在内存中
+type changeLog struct {
+	path    string
+	entries []*change
+}
+
+//newChangeLog ...构造函数，会从path中加载已有的修改历史
+func newChangeLog(path string) (*changeLog, error) {
+	cl := &changeLog{
+		path:    path,
+		entries: make([]*change, 0),
+	}
+	if path == "" {
+		return cl, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cl, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	//每行一个json，依次解码直到文件结束
+	dec := json.NewDecoder(f)
+	for {
+		var c change
+		err := dec.Decode(&c)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load history failed, err:%v", err)
+		}
+		cl.entries = append(cl.entries, &c)
+	}
+	return cl, nil
+}
+
+//append 给changes编号后追加到修改历史中
+//写入文件成功后才加入内存中的修改历史，写入失败时截掉写了一半的内容并清除编号，内存和文件中的修改历史保持一致
+func (cl *changeLog) append(changes []*change) (err error) {
+	for i, c := range changes {
+		c.Seq = len(cl.entries) + i + 1
+	}
+	defer func() {
+		if err != nil {
+			for _, c := range changes {
+				c.Seq = 0
+			}
+			return
+		}
+		cl.entries = append(cl.entries, changes...)
+	}()
+	if cl.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(cl.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, c := range changes {
+		if err = enc.Encode(c); err != nil {
+			f.Truncate(fi.Size())
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+//history 获取某个学员的修改历史，id为0时返回所有修改历史
+func (cl *changeLog) history(id int) []*change {
+	ret := make([]*change, 0)
+	for _, c := range cl.entries {
+		if id == 0 || c.ID == id {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+//currentUser 获取当前系统用户名，作为默认的操作人
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil || u.Username == "" {
+		return "unknown"
+	}
+	return u.Username
+}
+
+//commit 保存一组修改，保存成功后才更新内存中的学员信息，并把这组修改记录到修改历史和撤销栈中，
+//保存失败时内存中的学员信息、撤销栈和修改历史都保持不变；
+//保存成功后修改已经生效，写修改历史失败只打印警告，不再返回错误，调用方需持有写锁
+func (sm *studentMag) commit(op string, changes []*change) error {
+	if err := sm.persist(changes); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, c := range changes {
+		c.Who = sm.operator
+		c.When = now
+		c.Op = op
+	}
+	sm.undoStack = append(sm.undoStack, changes)
+	sm.redoStack = sm.redoStack[:0] //有了新的修改后，之前撤销的修改不能再重做
+	sm.appendLog(changes)
+	return nil
+}
+
+//persist 把changes依次应用到学员切片的拷贝上并保存，保存成功后再替换内存中的学员切片，调用方需持有写锁
+func (sm *studentMag) persist(changes []*change) error {
+	next := make([]*student, len(sm.students), len(sm.students)+len(changes))
+	copy(next, sm.students)
+	for _, c := range changes {
+		next = setState(next, c.ID, c.New)
+	}
+	if sm.store != nil {
+		if err := sm.store.Save(next, changes); err != nil {
+			return err
+		}
+	}
+	sm.students = next
+	return nil
+}
+
+//appendLog 把已经生效的修改记录到修改历史中，失败时只打印警告
+func (sm *studentMag) appendLog(changes []*change) {
+	if sm.log == nil {
+		return
+	}
+	if err := sm.log.append(changes); err != nil {
+		fmt.Printf("warning: changes saved but write history failed, err:%v\n", err)
+	}
+}
+
+//setState 把students中学号为id的学员设置为s，s为nil时删除该学员，返回修改后的切片
+//删除时会移动students中的元素，调用方传入的应该是拷贝
+func setState(students []*student, id int, s *student) []*student {
+	i := -1
+	for j, v := range students {
+		if v.id == id {
+			i = j
+			break
+		}
+	}
+	switch {
+	case s == nil && i != -1:
+		students = append(students[:i], students[i+1:]...)
+	case s != nil && i == -1:
+		students = append(students, s)
+	case s != nil:
+		students[i] = s
+	}
+	return students
+}
+
+//undo 撤销最近的一组修改
+func (sm *studentMag) undo() error {
+	sm.rwlock.Lock()
+	defer sm.rwlock.Unlock()
+	if len(sm.undoStack) == 0 {
+		return errNothingToUndo
+	}
+	group := sm.undoStack[len(sm.undoStack)-1]
+	changes := sm.reverted("undo", group, true)
+	if err := sm.persist(changes); err != nil {
+		return err
+	}
+	sm.undoStack = sm.undoStack[:len(sm.undoStack)-1]
+	sm.redoStack = append(sm.redoStack, group)
+	sm.appendLog(changes)
+	return nil
+}
+
+//redo 重做最近撤销的一组修改
+func (sm *studentMag) redo() error {
+	sm.rwlock.Lock()
+	defer sm.rwlock.Unlock()
+	if len(sm.redoStack) == 0 {
+		return errNothingToRedo
+	}
+	group := sm.redoStack[len(sm.redoStack)-1]
+	changes := sm.reverted("redo", group, false)
+	if err := sm.persist(changes); err != nil {
+		return err
+	}
+	sm.redoStack = sm.redoStack[:len(sm.redoStack)-1]
+	sm.undoStack = append(sm.undoStack, group)
+	sm.appendLog(changes)
+	return nil
+}
+
+//reverted 生成撤销或重做时要应用并记录到修改历史中的change
+//撤销时倒序生成，同一组中对同一个学员的多次修改也能正确撤销
+func (sm *studentMag) reverted(op string, group []*change, undo bool) []*change {
+	now := time.Now()
+	ret := make([]*change, 0, len(group))
+	for i := range group {
+		c := group[i]
+		if undo {
+			c = group[len(group)-1-i]
+		}
+		rc := &change{Who: sm.operator, When: now, Op: op, ID: c.ID, Old: c.Old, New: c.New, Ref: c.Seq}
+		if undo {
+			rc.Old, rc.New = c.New, c.Old
+		}
+		ret = append(ret, rc)
+	}
+	return ret
+}
+
+//studentHistory 获取某个学员的修改历史，id为0时返回所有修改历史
+func (sm *studentMag) studentHistory(id int) []*change {
+	sm.rwlock.RLock()
+	defer sm.rwlock.RUnlock()
+	if sm.log == nil {
+		return nil
+	}
+	return sm.log.history(id)
+}
+
+//printHistory 打印修改历史
+func printHistory(changes []*change) {
+	if len(changes) == 0 {
+		fmt.Println("没有修改记录")
+		return
+	}
+	for _, c := range changes {
+		fmt.Printf("#%d %s %s %s id:%d", c.Seq, c.When.Format("2006-01-02 15:04:05"), c.Who, c.Op, c.ID)
+		if c.Ref != 0 {
+			fmt.Printf(" ref:#%d", c.Ref)
+		}
+		fmt.Println()
+		fmt.Printf("  old:%s\n", describe(c.Old))
+		fmt.Printf("  new:%s\n", describe(c.New))
+	}
+}
+
+//describe 学员信息的单行描述，nil表示不存在
+func describe(s *student) string {
+	if s == nil {
+		return "<none>"
+	}
+	return fmt.Sprintf("name:%s class:%s enrolled:%s scores:%s", s.name, s.class, formatDate(s.enrolled), formatScores(s.scores))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChangeLogAppendFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("TempDir failed, err:%v", err)
+	}
+	defer os.RemoveAll(dir)
+	//目录不存在，写入修改历史会失败
+	cl, err := newChangeLog(filepath.Join(dir, "missing", "history.jsonl"))
+	if err != nil {
+		t.Fatalf("newChangeLog failed, err:%v", err)
+	}
+	c := &change{ID: 1, New: newStudent(1, "张三", "")}
+	if err := cl.append([]*change{c}); err == nil {
+		t.Fatalf("append succeeded, want error")
+	}
+	if len(cl.entries) != 0 || c.Seq != 0 {
+		t.Fatalf("entries = %d, seq = %d after failed append, want 0, 0", len(cl.entries), c.Seq)
+	}
+
+	//写入失败不影响已经生效的修改，commit照常返回成功
+	sm := newTestMagWithLog(t, cl)
+	if err := sm.addStudent(newStudent(1, "张三", "")); err != nil {
+		t.Fatalf("addStudent err = %v, want nil when only the history write failed", err)
+	}
+	if _, err := sm.getStudent(1); err != nil {
+		t.Fatalf("getStudent failed, err:%v", err)
+	}
+	if err := sm.undo(); err != nil {
+		t.Fatalf("undo err = %v, want nil", err)
+	}
+
+	//目录创建后可以继续写入，编号从1开始，和文件中的内容一致
+	if err := os.Mkdir(filepath.Join(dir, "missing"), 0755); err != nil {
+		t.Fatalf("Mkdir failed, err:%v", err)
+	}
+	if err := sm.redo(); err != nil {
+		t.Fatalf("redo failed, err:%v", err)
+	}
+	reloaded, err := newChangeLog(cl.path)
+	if err != nil {
+		t.Fatalf("newChangeLog failed, err:%v", err)
+	}
+	if len(reloaded.entries) != 1 || reloaded.entries[0].Seq != 1 || cl.entries[0].Seq != 1 {
+		t.Fatalf("history in file and memory differ: file %d entries, want 1 entry with seq 1", len(reloaded.entries))
+	}
+}
+
+//newTestMagWithLog 创建只保存在内存中的studentMag，修改历史写入cl
+func newTestMagWithLog(t *testing.T, cl *changeLog) *studentMag {
+	t.Helper()
+	sm, err := newstudentMag(nil)
+	if err != nil {
+		t.Fatalf("newstudentMag failed, err:%v", err)
+	}
+	sm.log = cl
+	return sm
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 )
@@ -12,6 +13,17 @@ import (
 //2、编辑学员信息
 //3、展示所有学员信息
 //4、退出系统
+//5、删除学员信息
+//6、按name或class搜索学员
+//7、学员信息保存在本地json文件中，重新打开系统时自动加载
+//8、支持命令行子命令，不带子命令时进入交互菜单
+//9、学员信息也可以保存在mysql中
+//10、记录学员的各科成绩和入学日期，按班级统计平均分、排名和前N名
+//11、记录修改历史，支持撤销和重做
+//12、按行读取输入，校验每一项输入，输入有误时重新输入
+
+//学员信息默认保存的文件
+const dataFile = "./students.json"
 
 func showMenu() {
 	fmt.Println()
@@ -20,63 +32,211 @@ func showMenu() {
 	fmt.Println("2、编辑学员信息")
 	fmt.Println("3、展示所有学员信息")
 	fmt.Println("4、退出系统")
+	fmt.Println("5、删除学员信息")
+	fmt.Println("6、搜索学员信息")
+	fmt.Println("7、各班级平均分")
+	fmt.Println("8、班级排名")
+	fmt.Println("9、前N名")
+	fmt.Println("10、撤销")
+	fmt.Println("11、重做")
+	fmt.Println("12、查看修改记录")
 	fmt.Println()
 }
 
-func getInput() *student {
-	defer fmt.Println("输入完成！")
-	var (
-		id    int
-		name  string
-		class string
-	)
-	//拿到用户输入的学员的信息
-	fmt.Println("请按要求输入学员信息")
-	fmt.Print("请输入学员的id：")
-	fmt.Scanf("%d\n", &id)
-	fmt.Print("请输入学员的name：")
-	fmt.Scanf("%s\n", &name)
-	fmt.Print("请输入学员的class：")
-	fmt.Scanf("%s\n", &class)
-	tmpstu := newStudent(id, name, class)
-	return tmpstu
+func main() {
+	dataPath := flag.String("data", dataFile, "保存学员信息的json文件")
+	storeType := flag.String("store", "json", "学员信息的存储方式：json、mysql")
+	historyPath := flag.String("history", historyFile, "保存修改历史的文件，为空时不保存")
+	operator := flag.String("user", currentUser(), "操作人，记录在修改历史中")
+	dsn := flag.String("dsn", "wancheng:wancheng@tcp(127.0.0.1:3306)/sql_test?charset=utf8mb4&parseTime=True", "store为mysql时使用的数据库连接")
+	flag.Usage = usage
+	flag.Parse()
+
+	var store Store
+	switch *storeType {
+	case "json":
+		store = newJSONStore(*dataPath)
+	case "mysql":
+		ms, err := newMySQLStore(*dsn)
+		if err != nil {
+			fmt.Printf("connect DB failed, err:%v\n", err)
+			os.Exit(1)
+		}
+		defer ms.Close()
+		store = ms
+	default:
+		fmt.Printf("unknown store %q\n", *storeType)
+		os.Exit(1)
+	}
+
+	sm, err := newstudentMag(store)
+	if err != nil {
+		fmt.Printf("load students failed, err:%v\n", err)
+		os.Exit(1)
+	}
+	if sm.log, err = newChangeLog(*historyPath); err != nil {
+		fmt.Printf("load history failed, err:%v\n", err)
+		os.Exit(1)
+	}
+	sm.operator = *operator
+	//带子命令时执行完直接退出
+	if flag.NArg() > 0 {
+		if err := runCommand(sm, flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed, err:%v\n", flag.Arg(0), err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Print("初始学员信息：")
+	fmt.Println(len(sm.listStudents()))
+	if err := runMenu(sm, newPrompter(os.Stdin)); err != nil && err != errInputClosed {
+		fmt.Printf("read input failed, err:%v\n", err)
+		os.Exit(1)
+	}
 }
 
-func main() {
-	sm := newstudentMag()
-	fmt.Print("初始学员信息为空：")
-	fmt.Println(sm.students)
+//runMenu 循环展示菜单并执行用户选择的动作，选择退出或标准输入关闭时返回
+func runMenu(sm *studentMag, p *prompter) error {
 	for {
 		//1、打印系统菜单
 		showMenu()
 		//2、等待用户选择要执行的选项
-		var input int
-		fmt.Print("请输入你的选择：")
-		fmt.Scanf("%d\n", &input)
-		fmt.Print("你输入的是：")
-		fmt.Println(input)
+		input, err := p.promptInt("请输入你的选择", 0, false)
+		if err != nil {
+			return err
+		}
 		fmt.Println()
 		//3、执行用户选择的动作
-		switch input {
-		case 1:
-			//添加学员
-			tmpstu := getInput()
-			sm.addStudent(tmpstu)
-		case 2:
-			//编辑学员
-			tmpstu := getInput()
-			sm.editStudent(tmpstu)
-		case 3:
-			//展示所有学员
-			sm.showStudent()
-		case 4:
+		if input == 4 {
 			//退出系统
-			os.Exit(0)
-		default:
-			//输入值不满足系统要求
-			fmt.Println()
-			fmt.Println("输入错误，请重新输入。")
-			fmt.Println()
+			return nil
+		}
+		if err := doAction(sm, p, input); err != nil {
+			return err
+		}
+	}
+}
+
+//doAction 执行菜单中的一个动作，只有读取输入失败时才返回错误，其他错误直接打印
+func doAction(sm *studentMag, p *prompter, input int) error {
+	switch input {
+	case 1:
+		//添加学员
+		fmt.Println("请按要求输入学员信息")
+		tmpstu, err := p.promptStudent(nil)
+		if err != nil {
+			return err
+		}
+		if err := sm.addStudent(tmpstu); err != nil {
+			fmt.Printf("add student failed, err:%v\n", err)
+			break
+		}
+		fmt.Println("添加成功！")
+	case 2:
+		//编辑学员，导入时的其他列保持不变
+		id, err := p.promptInt("请输入要编辑的学员的id", 0, false)
+		if err != nil {
+			return err
+		}
+		old, err := sm.getStudent(id)
+		if err != nil {
+			fmt.Printf("edit student failed, err:%v\n", err)
+			break
+		}
+		tmpstu, err := p.promptStudent(old)
+		if err != nil {
+			return err
+		}
+		if err := sm.editStudent(tmpstu); err != nil {
+			fmt.Printf("edit student failed, err:%v\n", err)
+			break
+		}
+		fmt.Println("编辑成功！")
+	case 3:
+		//展示所有学员
+		sm.showStudent()
+	case 5:
+		//删除学员
+		id, err := p.promptInt("请输入要删除的学员的id", 0, false)
+		if err != nil {
+			return err
+		}
+		if err := sm.deleteStudent(id); err != nil {
+			fmt.Printf("delete student failed, err:%v\n", err)
+			break
+		}
+		fmt.Println("删除成功！")
+	case 6:
+		//搜索学员
+		keyword, err := p.promptText("请输入要搜索的name或class", "", true)
+		if err != nil {
+			return err
+		}
+		ret := sm.searchStudent(keyword)
+		if len(ret) == 0 {
+			fmt.Println("没有找到匹配的学员")
+			break
+		}
+		printStudents(ret)
+	case 7:
+		//各班级平均分
+		sm.showClassAverages()
+	case 8:
+		//班级排名
+		title, err := p.promptText("请输入班级", "", false)
+		if err != nil {
+			return err
+		}
+		c, err := sm.getClass(title)
+		if err != nil {
+			fmt.Printf("get class failed, err:%v\n", err)
+			break
+		}
+		subject, err := p.promptSubject()
+		if err != nil {
+			return err
+		}
+		printRank(rank(c.Students, subject), subject)
+	case 9:
+		//所有学员中的前N名
+		n, err := p.promptInt("请输入N", 0, false)
+		if err != nil {
+			return err
+		}
+		subject, err := p.promptSubject()
+		if err != nil {
+			return err
+		}
+		items, err := topN(sm.listStudents(), subject, n)
+		if err != nil {
+			fmt.Printf("top n failed, err:%v\n", err)
+			break
+		}
+		printRank(items, subject)
+	case 10:
+		//撤销
+		if err := sm.undo(); err != nil {
+			fmt.Printf("undo failed, err:%v\n", err)
+			break
+		}
+		fmt.Println("撤销成功")
+	case 11:
+		//重做
+		if err := sm.redo(); err != nil {
+			fmt.Printf("redo failed, err:%v\n", err)
+			break
+		}
+		fmt.Println("重做成功")
+	case 12:
+		//查看修改记录
+		id, err := p.promptInt("请输入学员的id（直接回车查看所有修改记录）", 0, true)
+		if err != nil {
+			return err
 		}
+		printHistory(sm.studentHistory(id))
+	default:
+		//输入值不满足系统要求
+		fmt.Println("输入错误，请重新输入。")
 	}
+	return nil
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Moqqll/02goLearning/30db_mysql/sqlx/dbutil"
+	_ "github.com/go-sql-driver/mysql" //匿名导入，进初始化
+	"github.com/jmoiron/sqlx"
+)
+
+//mysqlStore 把学员信息保存在mysql的students表中，连接、事务和批量插入使用30db_mysql/sqlx中的dbutil
+type mysqlStore struct {
+	db *sqlx.DB
+}
+
+//studentRow students表中的一行，结构体字段首字母得大写，否则sqlx拿不到
+type studentRow struct {
+	ID       int    `db:"id"`
+	Name     string `db:"name"`
+	Class    string `db:"class"`
+	Scores   string `db:"scores"`   //scores序列化成json保存
+	Enrolled string `db:"enrolled"` //格式为2006-01-02
+	Extra    string `db:"extra"`    //extra序列化成json保存
+}
+
+//表中的所有列，顺序和values一致
+var studentColumns = []string{"id", "name", "class", "scores", "enrolled", "extra"}
+
+//newStudentRow 把学员转换为表中的一行
+func newStudentRow(s *student) (studentRow, error) {
+	r := studentRow{ID: s.id, Name: s.name, Class: s.class, Enrolled: formatDate(s.enrolled)}
+	if len(s.scores) > 0 {
+		b, err := json.Marshal(s.scores)
+		if err != nil {
+			return r, err
+		}
+		r.Scores = string(b)
+	}
+	if len(s.extra) > 0 {
+		b, err := json.Marshal(s.extra)
+		if err != nil {
+			return r, err
+		}
+		r.Extra = string(b)
+	}
+	return r, nil
+}
+
+//values 按studentColumns的顺序返回这一行的值
+func (r studentRow) values() []interface{} {
+	return []interface{}{r.ID, r.Name, r.Class, r.Scores, r.Enrolled, r.Extra}
+}
+
+//toStudent 把表中的一行转换为学员
+func (r studentRow) toStudent() (*student, error) {
+	s := newStudent(r.ID, r.Name, r.Class)
+	var err error
+	if s.enrolled, err = parseDate(r.Enrolled); err != nil {
+		return nil, fmt.Errorf("id:%d invalid enrolled, err:%v", r.ID, err)
+	}
+	if r.Scores != "" {
+		if err := json.Unmarshal([]byte(r.Scores), &s.scores); err != nil {
+			return nil, fmt.Errorf("id:%d invalid scores, err:%v", r.ID, err)
+		}
+	}
+	if r.Extra != "" {
+		if err := json.Unmarshal([]byte(r.Extra), &s.extra); err != nil {
+			return nil, fmt.Errorf("id:%d invalid extra, err:%v", r.ID, err)
+		}
+	}
+	return s, nil
+}
+
+//建表语句，表已存在时什么都不做
+const createStudentsTable = `create table if not exists students (
+	id int not null primary key,
+	name varchar(64) not null,
+	class varchar(64) not null default '',
+	scores varchar(1024) not null default '',
+	enrolled varchar(10) not null default '',
+	extra varchar(2048) not null default ''
+)`
+
+//旧版本的表中缺少的列，启动时自动补上
+var addedColumns = []struct {
+	name, definition string
+}{
+	{"extra", "varchar(2048) not null default ''"},
+	{"scores", "varchar(1024) not null default ''"},
+	{"enrolled", "varchar(10) not null default ''"},
+}
+
+//一次批量插入的最大行数，防止拼出来的语句占位符过多
+const batchSize = 500
+
+//newMySQLStore 连接数据库并建表
+//dsn示例：wancheng:wancheng@tcp(127.0.0.1:3306)/sql_test?charset=utf8mb4&parseTime=True
+func newMySQLStore(dsn string) (*mysqlStore, error) {
+	db, err := dbutil.Connect("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := newDBStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return ms, nil
+}
+
+//newDBStore 使用已经连接好的db并建表，测试时可以传入sqlite的连接
+func newDBStore(db *sqlx.DB) (*mysqlStore, error) {
+	ms := &mysqlStore{db: db}
+	if err := ms.migrate(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+//migrate 创建students表，并补上旧版本的表中缺少的列
+func (ms *mysqlStore) migrate() error {
+	if _, err := ms.db.Exec(createStudentsTable); err != nil {
+		return err
+	}
+	existing, err := ms.columns()
+	if err != nil {
+		return fmt.Errorf("query columns of students failed, err:%v", err)
+	}
+	for _, col := range addedColumns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := ms.db.Exec(fmt.Sprintf("alter table students add column %s %s", col.name, col.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//columns 查询students表中已有的列，列名统一转为小写
+//mysql从information_schema.columns中查询，测试时使用的sqlite用pragma_table_info查询
+func (ms *mysqlStore) columns() (map[string]bool, error) {
+	sqlStr := "select column_name from information_schema.columns where table_schema = database() and table_name = 'students'"
+	if ms.db.DriverName() == "sqlite3" {
+		sqlStr = "select name from pragma_table_info('students')"
+	}
+	var names []string
+	if err := ms.db.Select(&names, sqlStr); err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[strings.ToLower(name)] = true
+	}
+	return existing, nil
+}
+
+//Close 关闭数据库连接
+func (ms *mysqlStore) Close() error {
+	return ms.db.Close()
+}
+
+//Load 查询所有学员
+func (ms *mysqlStore) Load() ([]*student, error) {
+	var rows []studentRow
+	if err := ms.db.Select(&rows, "select "+strings.Join(studentColumns, ", ")+" from students order by id"); err != nil {
+		return nil, err
+	}
+	students := make([]*student, 0, len(rows))
+	for _, r := range rows {
+		s, err := r.toStudent()
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	return students, nil
+}
+
+//Save 在一个事务中按顺序执行changes对应的语句：添加的学员插入，删除的学员按学号删除，其余按学号更新
+//只修改changes涉及的行，其他程序同时写入的行不受影响；任何一条语句失败时整个事务回滚
+//连续添加的学员（比如导入）攒起来批量插入，每批最多batchSize行
+func (ms *mysqlStore) Save(students []*student, changes []*change) error {
+	return dbutil.Transaction(ms.db, func(tx *sqlx.Tx) error {
+		pending := make([][]interface{}, 0)
+		flush := func() error {
+			err := dbutil.BatchInsert(tx, "students", studentColumns, pending, batchSize)
+			pending = pending[:0]
+			return err
+		}
+		for _, c := range changes {
+			if c.New == nil {
+				if err := flush(); err != nil {
+					return err
+				}
+				if _, err := tx.Exec("delete from students where id=?", c.ID); err != nil {
+					return err
+				}
+				continue
+			}
+			r, err := newStudentRow(c.New)
+			if err != nil {
+				return err
+			}
+			if c.Old == nil {
+				pending = append(pending, r.values())
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("update students set name=?, class=?, scores=?, enrolled=?, extra=? where id=?",
+				r.Name, r.Class, r.Scores, r.Enrolled, r.Extra, r.ID); err != nil {
+				return err
+			}
+		}
+		return flush()
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//openTestStore 用内存中的sqlite代替mysql，setup在建表前执行，可以用来创建旧版本的表
+func openTestStore(t *testing.T, setup ...string) (*mysqlStore, *sqlx.DB) {
+	t.Helper()
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("connect failed, err:%v", err)
+	}
+	//内存数据库每个连接都是独立的，只使用一个连接
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, sqlStr := range setup {
+		if _, err := db.Exec(sqlStr); err != nil {
+			t.Fatalf("exec %q failed, err:%v", sqlStr, err)
+		}
+	}
+	ms, err := newDBStore(db)
+	if err != nil {
+		t.Fatalf("newDBStore failed, err:%v", err)
+	}
+	return ms, db
+}
+
+//newTestMag 使用ms创建studentMag，不记录修改历史
+func newTestMag(t *testing.T, ms *mysqlStore) *studentMag {
+	t.Helper()
+	sm, err := newstudentMag(ms)
+	if err != nil {
+		t.Fatalf("newstudentMag failed, err:%v", err)
+	}
+	return sm
+}
+
+//assertStored 检查表中的学员和want完全相同
+func assertStored(t *testing.T, ms *mysqlStore, want []*student) {
+	t.Helper()
+	got, err := ms.Load()
+	if err != nil {
+		t.Fatalf("Load failed, err:%v", err)
+	}
+	byID := make(map[int]*student, len(got))
+	for _, s := range got {
+		byID[s.id] = s
+	}
+	if len(byID) != len(want) {
+		t.Fatalf("stored %d students, want %d", len(byID), len(want))
+	}
+	for _, w := range want {
+		s, ok := byID[w.id]
+		if !ok {
+			t.Fatalf("id:%d not stored", w.id)
+		}
+		if !s.equal(w) {
+			t.Fatalf("id:%d stored %s, want %s", w.id, describe(s), describe(w))
+		}
+	}
+}
+
+func TestMySQLStoreOperations(t *testing.T) {
+	ms, _ := openTestStore(t)
+	sm := newTestMag(t, ms)
+
+	stu := newStudent(1, "张三", "火箭101")
+	stu.scores = map[string]float64{"语文": 90, "数学": 85.5}
+	stu.enrolled = time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	stu.extra = map[string]string{"phone": "123"}
+	steps := []struct {
+		name string
+		do   func() error
+	}{
+		{"add", func() error { return sm.addStudent(stu) }},
+		{"add another", func() error { return sm.addStudent(newStudent(2, "李四", "火箭101")) }},
+		{"edit", func() error { return sm.editStudent(newStudent(1, "张三", "火箭102")) }},
+		{"delete", func() error { return sm.deleteStudent(2) }},
+		{"import", func() error {
+			_, err := sm.importCSV(strings.NewReader("id,name,class\n1,张三,火箭103\n3,王五,\n4,赵六,火箭101\n"), false)
+			return err
+		}},
+		{"undo import", sm.undo},
+		{"undo delete", sm.undo},
+		{"redo delete", sm.redo},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s failed, err:%v", step.name, err)
+		}
+		assertStored(t, ms, sm.listStudents())
+	}
+}
+
+func TestMySQLStoreKeepsOtherWriters(t *testing.T) {
+	ms, db := openTestStore(t)
+	sm := newTestMag(t, ms)
+	if err := sm.addStudent(newStudent(1, "张三", "")); err != nil {
+		t.Fatalf("addStudent failed, err:%v", err)
+	}
+	//加载之后其他程序写入的行不在内存中，保存时不能被删除
+	if _, err := db.Exec("insert into students (id, name) values (?, ?)", 99, "其他程序"); err != nil {
+		t.Fatalf("insert failed, err:%v", err)
+	}
+	if err := sm.addStudent(newStudent(2, "李四", "")); err != nil {
+		t.Fatalf("addStudent failed, err:%v", err)
+	}
+	if err := sm.editStudent(newStudent(1, "张三", "火箭101")); err != nil {
+		t.Fatalf("editStudent failed, err:%v", err)
+	}
+	if err := sm.deleteStudent(2); err != nil {
+		t.Fatalf("deleteStudent failed, err:%v", err)
+	}
+	assertStored(t, ms, append(sm.listStudents(), newStudent(99, "其他程序", "")))
+}
+
+func TestMySQLStoreRollback(t *testing.T) {
+	ms, db := openTestStore(t)
+	sm := newTestMag(t, ms)
+	if err := sm.addStudent(newStudent(1, "张三", "")); err != nil {
+		t.Fatalf("addStudent failed, err:%v", err)
+	}
+	//其他程序已经写入了学号3，导入时插入会违反主键约束，整个导入都要回滚
+	if _, err := db.Exec("insert into students (id, name) values (?, ?)", 3, "其他程序"); err != nil {
+		t.Fatalf("insert failed, err:%v", err)
+	}
+	_, err := sm.importCSV(strings.NewReader("id,name,class\n1,张三,火箭101\n2,李四,\n3,王五,\n"), false)
+	if err == nil {
+		t.Fatalf("import succeeded, want primary key error")
+	}
+	want := []*student{newStudent(1, "张三", "")}
+	if got := sm.listStudents(); len(got) != 1 || !got[0].equal(want[0]) {
+		t.Fatalf("students in memory changed after failed import")
+	}
+	assertStored(t, ms, append(want, newStudent(3, "其他程序", "")))
+	if err := sm.undo(); err != nil {
+		t.Fatalf("undo failed, err:%v", err)
+	}
+	if err := sm.undo(); !errors.Is(err, errNothingToUndo) {
+		t.Fatalf("undo err = %v, want %v", err, errNothingToUndo)
+	}
+}
+
+func TestMySQLStoreMigrate(t *testing.T) {
+	//旧版本的表只有id、name、class三列
+	ms, db := openTestStore(t,
+		"create table students (id int not null primary key, name varchar(64) not null, class varchar(64) not null default '')",
+		"insert into students (id, name, class) values (1, '张三', '火箭101')",
+	)
+	existing, err := ms.columns()
+	if err != nil {
+		t.Fatalf("columns failed, err:%v", err)
+	}
+	for _, col := range studentColumns {
+		if !existing[col] {
+			t.Fatalf("column %s missing after migrate", col)
+		}
+	}
+	//列都已经存在时再次启动不会重复添加
+	if _, err := newDBStore(db); err != nil {
+		t.Fatalf("second newDBStore failed, err:%v", err)
+	}
+	stu := newStudent(2, "李四", "火箭101")
+	stu.scores = map[string]float64{"语文": 90}
+	stu.extra = map[string]string{"phone": "123"}
+	sm := newTestMag(t, ms)
+	if err := sm.addStudent(stu); err != nil {
+		t.Fatalf("addStudent failed, err:%v", err)
+	}
+	assertStored(t, ms, []*student{newStudent(1, "张三", "火箭101"), stu})
+}
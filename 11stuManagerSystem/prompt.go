@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//交互菜单的输入层：
+//参照21stdlib_fmt中的bufioDemo，用bufio.Reader按行读取输入，
+//这样name中可以有空格，输入错误时也不会有残留的数据留在标准输入中
+
+//name、class最多的字符数，和mysql中varchar(64)一致
+const maxNameLen = 64
+
+//errInputClosed 标准输入已关闭，交互菜单应该退出
+var errInputClosed = errors.New("input closed")
+
+//prompter 按行读取用户输入，输入不合法时提示错误并重新输入
+type prompter struct {
+	reader *bufio.Reader
+}
+
+//newPrompter ...构造函数
+func newPrompter(r io.Reader) *prompter {
+	return &prompter{
+		reader: bufio.NewReader(r),
+	}
+}
+
+//readLine 打印提示并读取一行，去掉首尾的空白字符，标准输入关闭时返回errInputClosed
+func (p *prompter) readLine(label string) (string, error) {
+	fmt.Print(label)
+	text, err := p.reader.ReadString('\n')
+	if err == io.EOF && text == "" {
+		fmt.Println()
+		return "", errInputClosed
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
+
+//prompt 读取一行并用parse校验，不合法时提示错误并重新输入
+//def不为空时会显示在提示中，直接回车表示使用def
+func (p *prompter) prompt(label, def string, parse func(string) error) (string, error) {
+	if def != "" {
+		label = fmt.Sprintf("%s[%s]", label, def)
+	}
+	label += "："
+	for {
+		text, err := p.readLine(label)
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			text = def
+		}
+		if !utf8.ValidString(text) {
+			err = errors.New("不是合法的UTF-8编码")
+		} else {
+			err = parse(text)
+		}
+		if err == nil {
+			return text, nil
+		}
+		fmt.Printf("输入有误：%v，请重新输入。\n", err)
+	}
+}
+
+//promptInt 输入一个整数，allowEmpty为true时直接回车返回def
+func (p *prompter) promptInt(label string, def int, allowEmpty bool) (int, error) {
+	var n int
+	_, err := p.prompt(label, "", func(s string) error {
+		if s == "" && allowEmpty {
+			n = def
+			return nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q不是整数", s)
+		}
+		n = v
+		return nil
+	})
+	return n, err
+}
+
+//promptText 输入一段文本，required为true时不能为空
+func (p *prompter) promptText(label, def string, required bool) (string, error) {
+	return p.prompt(label, def, func(s string) error {
+		if required && s == "" {
+			return errors.New("不能为空")
+		}
+		if utf8.RuneCountInString(s) > maxNameLen {
+			return fmt.Errorf("不能超过%d个字", maxNameLen)
+		}
+		return nil
+	})
+}
+
+//promptStudent 输入学员信息，old不为nil时是编辑学员，各项直接回车表示保持原值
+func (p *prompter) promptStudent(old *student) (*student, error) {
+	var newStu *student
+	var err error
+	if old == nil {
+		id, err := p.promptInt("请输入学员的id", 0, false)
+		if err != nil {
+			return nil, err
+		}
+		newStu = newStudent(id, "", "")
+	} else {
+		newStu = old.clone()
+		fmt.Println("直接回车表示保持原值，class、成绩和入学日期输入-表示清空")
+	}
+	if newStu.name, err = p.promptText("请输入学员的name", newStu.name, true); err != nil {
+		return nil, err
+	}
+	//class可以为空，编辑时无法通过回车清空，用-表示清空
+	class, err := p.promptText("请输入学员的class", newStu.class, false)
+	if err != nil {
+		return nil, err
+	}
+	newStu.class = clearable(class)
+	_, err = p.prompt("请输入学员的成绩（格式：语文:90,数学:85）", formatScores(newStu.scores), func(s string) error {
+		scores, err := parseScores(clearable(s))
+		if err != nil {
+			return err
+		}
+		newStu.scores = scores
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.prompt("请输入学员的入学日期（格式：2006-01-02）", formatDate(newStu.enrolled), func(s string) error {
+		enrolled, err := parseDate(clearable(s))
+		if err != nil {
+			return err
+		}
+		newStu.enrolled = enrolled
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newStu, nil
+}
+
+//clearable 输入-表示清空
+func clearable(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+//promptSubject 输入要排名的科目，直接回车按总分排名
+func (p *prompter) promptSubject() (string, error) {
+	return p.promptText("请输入科目", totalSubject, true)
+}
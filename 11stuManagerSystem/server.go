@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//通过http对外提供学员信息管理接口：
+//  GET    /students       获取所有学员
+//  POST   /students       添加学员，成功返回201，缺少id或学员信息不合法返回400，学号已存在返回409
+//  GET    /students/{id}  获取学员，不存在返回404
+//  PUT    /students/{id}  编辑学员，学员信息不合法返回400，不存在返回404
+//  DELETE /students/{id}  删除学员，不存在返回404
+
+//studentServer 持有studentMag，studentMag内部加了读写锁，可以被多个请求并发访问
+type studentServer struct {
+	sm *studentMag
+}
+
+//serve 启动http服务，直到服务出错才返回
+func serve(sm *studentMag, addr string) error {
+	ss := &studentServer{sm: sm}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/students", ss.studentsHandler)
+	mux.HandleFunc("/students/", ss.studentHandler)
+	fmt.Printf("http server listen on %s\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+//studentsHandler 处理/students
+func (ss *studentServer) studentsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, ss.sm.listStudents())
+	case http.MethodPost:
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("decode request body failed, err:%v", err))
+			return
+		}
+		//和命令行中的--id一样，id必须显式指定，不能因为省略而变成0
+		var idOnly struct {
+			ID *int `json:"id"`
+		}
+		var stu student
+		if err := json.Unmarshal(body, &idOnly); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("decode request body failed, err:%v", err))
+			return
+		}
+		if idOnly.ID == nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%w: id is required", errInvalid))
+			return
+		}
+		if err := json.Unmarshal(body, &stu); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("decode request body failed, err:%v", err))
+			return
+		}
+		if err := ss.sm.addStudent(&stu); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/students/%d", stu.id))
+		writeJSON(w, http.StatusCreated, &stu)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+//studentHandler 处理/students/{id}
+func (ss *studentServer) studentHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/students/"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("invalid student id %q", r.URL.Path))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		stu, err := ss.sm.getStudent(id)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, stu)
+	case http.MethodPut:
+		//学号以路径中的为准，请求体中的id可以省略
+		stu := student{id: id}
+		if err := json.NewDecoder(r.Body).Decode(&stu); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("decode request body failed, err:%v", err))
+			return
+		}
+		if stu.id != 0 && stu.id != id {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("id in body %d does not match id in path %d", stu.id, id))
+			return
+		}
+		stu.id = id
+		if err := ss.sm.editStudent(&stu); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &stu)
+	case http.MethodDelete:
+		if err := ss.sm.deleteStudent(id); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+//statusOf 把studentMag返回的错误转换为对应的http状态码
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, errInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+//writeJSON 先编码再写回复头，编码失败时回复500，而不是状态码正确、回复体却不完整
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	b, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("encode response failed, err:%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"internal server error"}` + "\n"))
+		return
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		fmt.Printf("write response failed, err:%v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//Store 学员信息的存储接口，studentMag通过它加载和保存学员信息
+//Save时students是应用changes后的全部学员，changes是这次按顺序发生的修改，
+//实现可以保存全部学员，也可以只保存changes涉及的学员
+type Store interface {
+	Load() ([]*student, error)
+	Save(students []*student, changes []*change) error
+}
+
+//jsonStore 把学员信息保存在本地json文件中
+type jsonStore struct {
+	path string
+}
+
+//newJSONStore ...构造函数
+func newJSONStore(path string) *jsonStore {
+	return &jsonStore{
+		path: path,
+	}
+}
+
+//Load 从json文件中加载学员信息，文件不存在时返回空列表
+func (js *jsonStore) Load() ([]*student, error) {
+	b, err := ioutil.ReadFile(js.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var students []*student
+	if err := json.Unmarshal(b, &students); err != nil {
+		return nil, err
+	}
+	return students, nil
+}
+
+//Save 先把全部学员信息写入同目录下的临时文件，再重命名为目标文件
+//这样即使写入过程中程序退出，原文件也不会被写坏
+func (js *jsonStore) Save(students []*student, changes []*change) (err error) {
+	b, err := json.MarshalIndent(students, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(js.path), filepath.Base(js.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	//写入失败时清理临时文件
+	defer func() {
+		if err != nil {
+			os.Remove(tmpFile.Name())
+		}
+	}()
+	if _, err = tmpFile.Write(b); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), js.path)
+}
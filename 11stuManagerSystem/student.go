@@ -1,11 +1,31 @@
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	//errNotFound 要操作的学员不存在
+	errNotFound = errors.New("student not found")
+	//errExists 要添加的学号已经存在
+	errExists = errors.New("student already exists")
+	//errInvalid 学员信息不合法
+	errInvalid = errors.New("invalid student")
+)
 
 type student struct {
-	id    int //学号唯一
-	name  string
-	class string
+	id       int //学号唯一
+	name     string
+	class    string
+	scores   map[string]float64 //各科成绩，科目->分数
+	enrolled time.Time          //入学日期，零值表示未填写
+	extra    map[string]string  //导入名单时除id、name、class等以外的列
 }
 
 //student ...构造函数
@@ -17,36 +37,247 @@ func newStudent(id int, name, class string) *student {
 	}
 }
 
+//clone 拷贝一份学员信息，修改拷贝不会影响原来的学员
+func (s *student) clone() *student {
+	newStu := newStudent(s.id, s.name, s.class)
+	newStu.enrolled = s.enrolled
+	if s.scores != nil {
+		newStu.scores = make(map[string]float64, len(s.scores))
+		for k, v := range s.scores {
+			newStu.scores[k] = v
+		}
+	}
+	if s.extra != nil {
+		newStu.extra = make(map[string]string, len(s.extra))
+		for k, v := range s.extra {
+			newStu.extra[k] = v
+		}
+	}
+	return newStu
+}
+
+//validate 校验学员信息，命令行、交互菜单、http接口和导入名单都按同样的规则校验：
+//name不能为空，name和class必须是合法的UTF-8编码并且不能超过maxNameLen个字，成绩要合法
+func (s *student) validate() error {
+	if strings.TrimSpace(s.name) == "" {
+		return fmt.Errorf("%w: name is empty", errInvalid)
+	}
+	for _, v := range []string{s.name, s.class} {
+		if !utf8.ValidString(v) {
+			return fmt.Errorf("%w: %q is not valid UTF-8", errInvalid, v)
+		}
+		if utf8.RuneCountInString(v) > maxNameLen {
+			return fmt.Errorf("%w: %q is longer than %d characters", errInvalid, v, maxNameLen)
+		}
+	}
+	if err := checkScores(s.scores); err != nil {
+		return fmt.Errorf("%w: %v", errInvalid, err)
+	}
+	return nil
+}
+
+//equal 判断两个学员的信息是否完全相同
+func (s *student) equal(other *student) bool {
+	if s.id != other.id || s.name != other.name || s.class != other.class || !s.enrolled.Equal(other.enrolled) {
+		return false
+	}
+	if len(s.scores) != len(other.scores) || len(s.extra) != len(other.extra) {
+		return false
+	}
+	for k, v := range s.scores {
+		if ov, ok := other.scores[k]; !ok || ov != v {
+			return false
+		}
+	}
+	for k, v := range s.extra {
+		if ov, ok := other.extra[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+//studentJSON student字段是小写的，json包拿不到，序列化时借助这个结构体中转
+type studentJSON struct {
+	ID       int                `json:"id"`
+	Name     string             `json:"name"`
+	Class    string             `json:"class"`
+	Scores   map[string]float64 `json:"scores,omitempty"`
+	Enrolled string             `json:"enrolled,omitempty"` //格式为2006-01-02
+	Extra    map[string]string  `json:"extra,omitempty"`
+}
+
+//MarshalJSON ...
+func (s *student) MarshalJSON() ([]byte, error) {
+	return json.Marshal(studentJSON{
+		ID:       s.id,
+		Name:     s.name,
+		Class:    s.class,
+		Scores:   s.scores,
+		Enrolled: formatDate(s.enrolled),
+		Extra:    s.extra,
+	})
+}
+
+//UnmarshalJSON ...
+func (s *student) UnmarshalJSON(b []byte) error {
+	var tmp studentJSON
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+	enrolled, err := parseDate(tmp.Enrolled)
+	if err != nil {
+		return err
+	}
+	if err := checkScores(tmp.Scores); err != nil {
+		return err
+	}
+	s.id = tmp.ID
+	s.name = tmp.Name
+	s.class = tmp.Class
+	s.scores = tmp.Scores
+	s.enrolled = enrolled
+	s.extra = tmp.Extra
+	return nil
+}
+
 //Students ...
 type studentMag struct {
-	students []*student
+	rwlock    sync.RWMutex //http服务中多个goroutine会同时访问students
+	students  []*student
+	store     Store      //为nil时学员信息只保存在内存中
+	log       *changeLog //为nil时不记录修改历史
+	operator  string     //当前操作人，记录在修改历史中
+	undoStack [][]*change
+	redoStack [][]*change
 }
 
-//newStudents ...构造函数
-func newstudentMag() *studentMag {
-	return &studentMag{
+//newStudents ...构造函数，会从store中加载已有的学员信息
+func newstudentMag(store Store) (*studentMag, error) {
+	sm := &studentMag{
 		students: make([]*student, 0, 100),
+		store:    store,
+		operator: currentUser(),
+	}
+	if store == nil {
+		return sm, nil
 	}
+	students, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	sm.students = append(sm.students, students...)
+	return sm, nil
+}
+
+//根据学号查找学员在切片中的索引，找不到时返回-1，调用方需持有锁
+func (sm *studentMag) indexOf(id int) int {
+	for i, v := range sm.students {
+		if v.id == id {
+			return i
+		}
+	}
+	return -1
 }
 
-//添加学员
-func (sm *studentMag) addStudent(newStu *student) {
-	sm.students = append(sm.students, newStu)
+//根据学号获取学员，学号不存在时返回errNotFound
+func (sm *studentMag) getStudent(id int) (*student, error) {
+	sm.rwlock.RLock()
+	defer sm.rwlock.RUnlock()
+	i := sm.indexOf(id)
+	if i == -1 {
+		return nil, fmt.Errorf("id:%d %w", id, errNotFound)
+	}
+	return sm.students[i], nil
 }
 
-//编辑学员
-func (sm *studentMag) editStudent(newStu *student) {
-	for i, v := range sm.students {
-		if newStu.id == v.id { //当学号相同时，就表示找到了需要编辑的学员
-			sm.students[i] = newStu //把新学员信息根据切片索引直接赋能给切片相应元素项，即用新的学员信息替换旧的学员信息。
+//添加学员，学号已存在时返回errExists，学员信息不合法时返回errInvalid
+func (sm *studentMag) addStudent(newStu *student) error {
+	if err := newStu.validate(); err != nil {
+		return err
+	}
+	sm.rwlock.Lock()
+	defer sm.rwlock.Unlock()
+	c, err := sm.add(newStu)
+	if err != nil {
+		return err
+	}
+	return sm.commit("add", []*change{c})
+}
+
+//add 生成添加学员的修改，不修改学员信息，调用方需持有写锁
+func (sm *studentMag) add(newStu *student) (*change, error) {
+	if sm.indexOf(newStu.id) != -1 {
+		return nil, fmt.Errorf("id:%d %w", newStu.id, errExists)
+	}
+	return &change{ID: newStu.id, New: newStu}, nil
+}
+
+//编辑学员，学号不存在时返回errNotFound，学员信息不合法时返回errInvalid
+func (sm *studentMag) editStudent(newStu *student) error {
+	if err := newStu.validate(); err != nil {
+		return err
+	}
+	sm.rwlock.Lock()
+	defer sm.rwlock.Unlock()
+	c, err := sm.edit(newStu)
+	if err != nil {
+		return err
+	}
+	return sm.commit("edit", []*change{c})
+}
+
+//edit 生成编辑学员的修改，不修改学员信息，调用方需持有写锁
+func (sm *studentMag) edit(newStu *student) (*change, error) {
+	i := sm.indexOf(newStu.id) //当学号相同时，就表示找到了需要编辑的学员
+	if i == -1 {
+		return nil, fmt.Errorf("id:%d %w", newStu.id, errNotFound)
+	}
+	return &change{ID: newStu.id, Old: sm.students[i], New: newStu}, nil
+}
+
+//删除学员，学号不存在时返回errNotFound
+func (sm *studentMag) deleteStudent(id int) error {
+	sm.rwlock.Lock()
+	defer sm.rwlock.Unlock()
+	i := sm.indexOf(id)
+	if i == -1 {
+		return fmt.Errorf("id:%d %w", id, errNotFound)
+	}
+	return sm.commit("delete", []*change{{ID: id, Old: sm.students[i]}})
+}
+
+//搜索学员，返回name或class中包含关键字的学员
+func (sm *studentMag) searchStudent(keyword string) []*student {
+	sm.rwlock.RLock()
+	defer sm.rwlock.RUnlock()
+	ret := make([]*student, 0)
+	for _, v := range sm.students {
+		if strings.Contains(v.name, keyword) || strings.Contains(v.class, keyword) {
+			ret = append(ret, v)
 		}
 	}
+	return ret
+}
+
+//获取所有学员，返回的是切片的拷贝，调用方可以放心遍历
+func (sm *studentMag) listStudents() []*student {
+	sm.rwlock.RLock()
+	defer sm.rwlock.RUnlock()
+	ret := make([]*student, len(sm.students))
+	copy(ret, sm.students)
+	return ret
 }
 
 //展示学员
 func (sm *studentMag) showStudent() {
 	fmt.Println("系统中已有以下学员：")
-	for _, v := range sm.students {
-		fmt.Printf("id:%d name:%s class:%s\n", v.id, v.name, v.class)
+	printStudents(sm.listStudents())
+}
+
+//打印学员列表
+func printStudents(students []*student) {
+	for _, v := range students {
+		fmt.Printf("id:%d name:%s class:%s enrolled:%s scores:%s\n", v.id, v.name, v.class, formatDate(v.enrolled), formatScores(v.scores))
 	}
 }
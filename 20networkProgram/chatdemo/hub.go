@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//聊天室的所有状态（在线用户）都只由hub这一个goroutine读写，
+//连接的处理函数通过channel把请求发送给hub，参照18channel中worker通过channel接收任务的方式，
+//这样就不需要给共享的map加锁
+
+//errHubClosed 聊天室已经关闭
+var errHubClosed = errors.New("聊天室已关闭")
+
+//每个用户待发送消息的缓冲区大小，缓冲区满了说明该用户接收太慢，会被踢出聊天室
+const outBufferSize = 64
+
+//client 一个在线用户
+type client struct {
+	nick string
+	addr string
+	out  chan string //待发送给该用户的消息，只由hub关闭
+}
+
+//joinReq 加入聊天室的请求，昵称已被使用时通过reply返回错误
+type joinReq struct {
+	c     *client
+	reply chan error
+}
+
+//message 用户发送的消息，to为空时广播给所有人，否则是私聊
+type message struct {
+	from   *client
+	to     string
+	text   string
+	notice bool //为true时表示是给from自己的提示，原样发送给from
+}
+
+//hub 聊天室
+type hub struct {
+	join     chan *joinReq
+	leave    chan *client
+	messages chan *message
+	list     chan *client
+	done     chan struct{} //hub退出后关闭
+
+	clients map[string]*client //只在run中访问
+}
+
+//newHub ...构造函数
+func newHub() *hub {
+	return &hub{
+		join:     make(chan *joinReq),
+		leave:    make(chan *client),
+		messages: make(chan *message),
+		list:     make(chan *client),
+		done:     make(chan struct{}),
+		clients:  make(map[string]*client),
+	}
+}
+
+//run 处理所有请求，直到ctx被取消
+func (h *hub) run(ctx context.Context) {
+	defer close(h.done)
+	for {
+		select {
+		case <-ctx.Done():
+			for _, c := range h.clients {
+				h.remove(c)
+			}
+			return
+		case req := <-h.join:
+			if _, ok := h.clients[req.c.nick]; ok {
+				req.reply <- fmt.Errorf("昵称%s已被使用", req.c.nick)
+				continue
+			}
+			h.broadcast(nil, fmt.Sprintf("* %s 加入了聊天室", req.c.nick))
+			h.clients[req.c.nick] = req.c
+			req.reply <- nil
+			fmt.Printf("%s joined as %s, online:%d\n", req.c.addr, req.c.nick, len(h.clients))
+		case c := <-h.leave:
+			//被踢出的用户已经不在clients中了
+			if h.clients[c.nick] != c {
+				continue
+			}
+			h.remove(c)
+			h.broadcast(nil, fmt.Sprintf("* %s 离开了聊天室", c.nick))
+			fmt.Printf("%s(%s) left, online:%d\n", c.nick, c.addr, len(h.clients))
+		case m := <-h.messages:
+			if h.clients[m.from.nick] != m.from {
+				continue
+			}
+			h.handleMessage(m)
+		case c := <-h.list:
+			if h.clients[c.nick] != c {
+				continue
+			}
+			nicks := make([]string, 0, len(h.clients))
+			for nick := range h.clients {
+				nicks = append(nicks, nick)
+			}
+			sort.Strings(nicks)
+			h.deliver(c, fmt.Sprintf("在线用户(%d)：%s", len(nicks), strings.Join(nicks, ", ")))
+		}
+	}
+}
+
+//handleMessage 广播或者私聊
+func (h *hub) handleMessage(m *message) {
+	if m.notice {
+		h.deliver(m.from, m.text)
+		return
+	}
+	now := time.Now().Format("15:04:05")
+	if m.to == "" {
+		h.broadcast(m.from, fmt.Sprintf("[%s] %s: %s", now, m.from.nick, m.text))
+		return
+	}
+	to, ok := h.clients[m.to]
+	if !ok {
+		h.deliver(m.from, fmt.Sprintf("用户%s不在线", m.to))
+		return
+	}
+	h.deliver(to, fmt.Sprintf("[%s] %s 对你说: %s", now, m.from.nick, m.text))
+	if to != m.from {
+		h.deliver(m.from, fmt.Sprintf("[%s] 你对 %s 说: %s", now, to.nick, m.text))
+	}
+}
+
+//broadcast 把消息发送给除了except之外的所有在线用户
+func (h *hub) broadcast(except *client, msg string) {
+	for _, c := range h.clients {
+		if c != except {
+			h.deliver(c, msg)
+		}
+	}
+}
+
+//deliver 把消息放入用户的发送缓冲区，缓冲区满时把该用户踢出聊天室，不会阻塞hub
+func (h *hub) deliver(c *client, msg string) {
+	select {
+	case c.out <- msg:
+	default:
+		fmt.Printf("%s(%s) is too slow, kicked\n", c.nick, c.addr)
+		h.remove(c)
+	}
+}
+
+//remove 把用户移出聊天室并关闭其发送缓冲区，发送协程发完剩下的消息后会关闭连接
+func (h *hub) remove(c *client) {
+	delete(h.clients, c.nick)
+	close(c.out)
+}
+
+//addClient 加入聊天室，昵称已被使用或者hub已经退出时返回错误
+func (h *hub) addClient(c *client) error {
+	req := &joinReq{c: c, reply: make(chan error, 1)}
+	select {
+	case h.join <- req:
+		return <-req.reply
+	case <-h.done:
+		return errHubClosed
+	}
+}
+
+//removeClient 离开聊天室
+func (h *hub) removeClient(c *client) {
+	select {
+	case h.leave <- c:
+	case <-h.done:
+	}
+}
+
+//post 发送消息
+func (h *hub) post(m *message) {
+	select {
+	case h.messages <- m:
+	case <-h.done:
+	}
+}
+
+//notify 给c发送一条提示
+func (h *hub) notify(c *client, text string) {
+	h.post(&message{from: c, text: text, notice: true})
+}
+
+//listClients 请求在线用户列表，结果会发送到c.out中
+func (h *hub) listClients(c *client) {
+	select {
+	case h.list <- c:
+	case <-h.done:
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/proto"
+	"github.com/Moqqll/02goLearning/20networkProgram/tcpserver"
+)
+
+// TCP聊天室服务端，在tcpdemo的基础上实现，消息格式同样使用proto，可以直接用tcpdemo/client连接
+//连接后先输入昵称，之后输入的内容会广播给所有人，支持以下命令：
+//  /msg nick text 私聊
+//  /list          查看在线用户
+//  /quit          退出
+
+var (
+	addr            = flag.String("addr", "127.0.0.1:2001", "监听的地址")
+	maxConns        = flag.Int("max-conns", 100, "最大连接数，0表示不限制")
+	idleTimeout     = flag.Duration("idle-timeout", 10*time.Minute, "连接的空闲超时时间，0表示不限制")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "关闭时等待连接处理完的最长时间")
+)
+
+//昵称最多的字符数
+const maxNickLen = 32
+
+const helpText = "命令：/msg nick text 私聊，/list 查看在线用户，/quit 退出"
+
+//write 编码后发送一条消息
+func write(conn net.Conn, msg string) error {
+	b, err := proto.Encode(msg)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(b)
+	return err
+}
+
+//checkNick 昵称不能为空，不能包含空白字符，不能以/开头
+func checkNick(nick string) error {
+	if nick == "" {
+		return fmt.Errorf("昵称不能为空")
+	}
+	if utf8.RuneCountInString(nick) > maxNickLen {
+		return fmt.Errorf("昵称不能超过%d个字", maxNickLen)
+	}
+	if strings.ContainsAny(nick, " \t\r\n") || strings.HasPrefix(nick, "/") {
+		return fmt.Errorf("昵称不能包含空白字符，也不能以/开头")
+	}
+	return nil
+}
+
+//chatHandler 返回处理一个聊天连接的函数
+func chatHandler(h *hub) tcpserver.Handler {
+	return func(ctx context.Context, conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		c := &client{addr: conn.RemoteAddr().String(), out: make(chan string, outBufferSize)}
+
+		//加入聊天室之前只有当前goroutine会写连接，直接发送
+		if err := write(conn, "欢迎来到聊天室，请输入昵称："); err != nil {
+			return
+		}
+		for {
+			if reader.Buffered() == 0 {
+				tcpserver.Idle(conn)
+			}
+			nick, err := proto.Decode(reader)
+			if err != nil {
+				return
+			}
+			c.nick = strings.TrimSpace(nick)
+			if err = checkNick(c.nick); err == nil {
+				err = h.addClient(c)
+			}
+			if err == nil {
+				break
+			}
+			if err == errHubClosed {
+				write(conn, err.Error())
+				return
+			}
+			if err := write(conn, fmt.Sprintf("%v，请重新输入昵称：", err)); err != nil {
+				return
+			}
+		}
+
+		//加入聊天室之后由单独的goroutine发送c.out中的消息，hub关闭c.out后发完剩下的消息再关闭连接
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			for msg := range c.out {
+				if err := write(conn, msg); err != nil {
+					break
+				}
+			}
+			conn.Close()
+			for range c.out { //连接出错后丢弃剩下的消息，直到hub关闭c.out
+			}
+		}()
+		defer func() {
+			h.removeClient(c)
+			<-written //等待剩下的消息发完
+		}()
+
+		h.notify(c, fmt.Sprintf("你好，%s！%s", c.nick, helpText))
+		for {
+			//hub可能同时在写连接，只有读取的一侧处理完一行后才处于两条消息之间
+			if reader.Buffered() == 0 {
+				tcpserver.Idle(conn)
+			}
+			text, err := proto.Decode(reader)
+			if err == io.EOF || ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				fmt.Printf("read from %s failed, err:%v\n", c.addr, err)
+				return
+			}
+			if !handleLine(h, c, strings.TrimSpace(text)) {
+				return
+			}
+		}
+	}
+}
+
+//parseMsg 解析私聊命令"/msg nick text"，命令和昵称之间、昵称和内容之间可以有多个空格或制表符，
+//昵称之后的部分去掉首尾空白后原样作为消息内容，缺少昵称或内容时ok为false
+func parseMsg(text string) (nick, msg string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) < 3 {
+		return "", "", false
+	}
+	rest := strings.TrimSpace(strings.TrimSpace(text)[len(fields[0]):])
+	return fields[1], strings.TrimSpace(rest[len(fields[1]):]), true
+}
+
+//handleLine 处理用户输入的一行，返回false表示用户要退出
+func handleLine(h *hub, c *client, text string) bool {
+	if text == "" {
+		return true
+	}
+	if !strings.HasPrefix(text, "/") {
+		h.post(&message{from: c, text: text})
+		return true
+	}
+	switch strings.Fields(text)[0] {
+	case "/quit":
+		return false
+	case "/list":
+		h.listClients(c)
+	case "/msg":
+		nick, msg, ok := parseMsg(text)
+		if !ok {
+			h.notify(c, "用法：/msg nick text")
+			break
+		}
+		h.post(&message{from: c, to: nick, text: msg})
+	default:
+		h.notify(c, "未知命令，"+helpText)
+	}
+	return true
+}
+
+func main() {
+	flag.Parse()
+	h := newHub()
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	go h.run(hubCtx)
+
+	srv := &tcpserver.Server{
+		Addr:        *addr,
+		Handler:     chatHandler(h),
+		MaxConns:    *maxConns,
+		IdleTimeout: *idleTimeout,
+	}
+
+	//收到SIGINT或SIGTERM时优雅关闭，所有连接结束后再关闭hub
+	stopped := make(chan struct{})
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigChan
+		fmt.Printf("received %v, shutting down...\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Printf("shutdown failed, err:%v\n", err)
+		}
+		stopHub()
+		<-h.done
+		close(stopped)
+	}()
+
+	fmt.Printf("chat server listening on %s\n", *addr)
+	if err := srv.Serve(context.Background()); err != tcpserver.ErrServerClosed {
+		fmt.Printf("serve failed, err:%v\n", err)
+		return
+	}
+	<-stopped
+	fmt.Println("server stopped")
+}
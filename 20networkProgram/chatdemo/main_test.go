@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseMsg(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		wantNick string
+		wantMsg  string
+		wantOK   bool
+	}{
+		{"single spaces", "/msg bob hi", "bob", "hi", true},
+		{"double space", "/msg  bob hi", "bob", "hi", true},
+		{"tabs", "/msg\tbob\thi there", "bob", "hi there", true},
+		{"keep inner spaces", "/msg bob  hello   world ", "bob", "hello   world", true},
+		{"missing text", "/msg bob", "", "", false},
+		{"missing text with spaces", "/msg bob   ", "", "", false},
+		{"missing nick", "/msg", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nick, msg, ok := parseMsg(tt.text)
+			if nick != tt.wantNick || msg != tt.wantMsg || ok != tt.wantOK {
+				t.Fatalf("parseMsg(%q) = %q, %q, %v, want %q, %q, %v",
+					tt.text, nick, msg, ok, tt.wantNick, tt.wantMsg, tt.wantOK)
+			}
+		})
+	}
+}
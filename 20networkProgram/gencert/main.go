@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//在本地生成一个自签名的CA，并用它签发服务端和客户端的证书，用于测试TLS，不需要任何外部的基础设施
+//生成的文件：
+//  ca.pem、ca-key.pem          CA证书和私钥
+//  server.pem、server-key.pem  服务端证书和私钥，包含--hosts中的域名和IP
+//  client.pem、client-key.pem  客户端证书和私钥，用于双向认证
+
+var (
+	dir   = flag.String("dir", "./certs", "证书保存的目录")
+	hosts = flag.String("hosts", "127.0.0.1,localhost", "服务端证书中的域名和IP，用逗号分隔")
+	days  = flag.Int("days", 365, "证书的有效天数")
+	cn    = flag.String("client-cn", "tcpdemo-client", "客户端证书的CommonName")
+)
+
+//certKey 证书和对应的私钥
+type certKey struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+//newSerial 生成随机的证书序列号
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+//issue 用parent签发template，parent为nil时自签名
+func issue(template *x509.Certificate, parent *certKey, name string) (*certKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour) //防止两台机器的时间有误差
+	template.NotAfter = time.Now().AddDate(0, 0, *days)
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePEM(name+".pem", "CERTIFICATE", der, 0644); err != nil {
+		return nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePEM(name+"-key.pem", "EC PRIVATE KEY", keyDer, 0600); err != nil {
+		return nil, err
+	}
+	return &certKey{cert: cert, key: key}, nil
+}
+
+//writePEM 以PEM格式写入文件，私钥文件只有自己可以读
+func writePEM(name, typ string, der []byte, perm os.FileMode) error {
+	path := filepath.Join(*dir, name)
+	b := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := ioutil.WriteFile(path, b, perm); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", path)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Printf("create dir failed, err:%v\n", err)
+		os.Exit(1)
+	}
+
+	ca, err := issue(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "tcpdemo CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, "ca")
+	if err != nil {
+		fmt.Printf("generate ca failed, err:%v\n", err)
+		os.Exit(1)
+	}
+
+	//服务端证书中要包含客户端连接时使用的地址，否则客户端校验域名会失败
+	server := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "tcpdemo server"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range strings.Split(*hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			server.IPAddresses = append(server.IPAddresses, ip)
+		} else {
+			server.DNSNames = append(server.DNSNames, h)
+		}
+	}
+	if _, err := issue(server, ca, "server"); err != nil {
+		fmt.Printf("generate server certificate failed, err:%v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: *cn},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, "client"); err != nil {
+		fmt.Printf("generate client certificate failed, err:%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//解决TCP黏包问题的消息格式：
+//每条消息前面加上4个字节的消息头，以小端序保存消息体的长度，后面紧跟着消息体
+//  +----------------+------------------+
+//  | length(uint32) | body(length字节) |
+//  +----------------+------------------+
+
+//headerLen 消息头的长度
+const headerLen = 4
+
+//MaxMessageLen 单条消息体的最大长度，防止对端发送错误的长度导致分配过大的内存
+const MaxMessageLen = 4 << 20
+
+//ErrTooLarge 消息体超过了MaxMessageLen
+var ErrTooLarge = errors.New("proto: message too large")
+
+//Encode 将消息编码为消息头+消息体
+func Encode(message string) ([]byte, error) {
+	if len(message) > MaxMessageLen {
+		return nil, ErrTooLarge
+	}
+	//读取消息的长度，转换成uint32类型（占4个字节）
+	var length = uint32(len(message))
+	var pkg = new(bytes.Buffer)
+	pkg.Grow(headerLen + len(message))
+	//写入消息头
+	if err := binary.Write(pkg, binary.LittleEndian, length); err != nil {
+		return nil, err
+	}
+	//写入消息体
+	pkg.WriteString(message)
+	return pkg.Bytes(), nil
+}
+
+//Decode 从reader中读取并解码一条完整的消息
+//一次Read可能只读到半条消息，也可能读到多条消息，所以这里用io.ReadFull按长度读取，
+//调用方应该对同一个连接一直使用同一个bufio.Reader，避免多次系统调用
+//在消息边界上读到连接关闭时返回io.EOF，消息读到一半连接关闭时返回io.ErrUnexpectedEOF
+func Decode(reader io.Reader) (string, error) {
+	//读取消息头，得到消息体的长度
+	var header [headerLen]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return "", err
+	}
+	length := binary.LittleEndian.Uint32(header[:])
+	if length > MaxMessageLen {
+		return "", fmt.Errorf("%w: %d bytes", ErrTooLarge, length)
+	}
+	//读取完整的消息体
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return string(body), nil
+}
@@ -0,0 +1,135 @@
+package proto
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"empty", ""},
+		{"ascii", "hello"},
+		{"utf8", "你好，世界"},
+		{"crlf", "a\r\nb\r\n"},
+		{"max length", strings.Repeat("x", MaxMessageLen)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg, err := Encode(tt.message)
+			if err != nil {
+				t.Fatalf("Encode failed, err:%v", err)
+			}
+			if len(pkg) != headerLen+len(tt.message) {
+				t.Fatalf("len(Encode) = %d, want %d", len(pkg), headerLen+len(tt.message))
+			}
+			//每次Read只返回一个字节，模拟消息被拆成多个TCP包
+			reader := iotest.OneByteReader(bytes.NewReader(pkg))
+			got, err := Decode(reader)
+			if err != nil {
+				t.Fatalf("Decode failed, err:%v", err)
+			}
+			if got != tt.message {
+				t.Fatalf("Decode = %q, want %q", shorten(got), shorten(tt.message))
+			}
+			if _, err := Decode(reader); err != io.EOF {
+				t.Fatalf("Decode at end of stream err = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestDecodeMultipleFrames(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+	}{
+		{"two frames", []string{"hello", "world"}},
+		{"empty frame between", []string{"a", "", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, m := range tt.messages {
+				pkg, err := Encode(m)
+				if err != nil {
+					t.Fatalf("Encode failed, err:%v", err)
+				}
+				buf.Write(pkg)
+			}
+			//所有消息在一次Read中全部读到，模拟黏包
+			reader := bufio.NewReader(&buf)
+			for _, want := range tt.messages {
+				got, err := Decode(reader)
+				if err != nil {
+					t.Fatalf("Decode failed, err:%v", err)
+				}
+				if got != want {
+					t.Fatalf("Decode = %q, want %q", got, want)
+				}
+			}
+			if _, err := Decode(reader); err != io.EOF {
+				t.Fatalf("Decode at end of stream err = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	pkg, err := Encode("hello")
+	if err != nil {
+		t.Fatalf("Encode failed, err:%v", err)
+	}
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"partial header", pkg[:2]},
+		{"header only", pkg[:headerLen]},
+		{"partial body", pkg[:len(pkg)-1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(iotest.OneByteReader(bytes.NewReader(tt.input)))
+			if err != io.ErrUnexpectedEOF {
+				t.Fatalf("Decode err = %v, want io.ErrUnexpectedEOF", err)
+			}
+		})
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	if _, err := Encode(strings.Repeat("x", MaxMessageLen+1)); err != ErrTooLarge {
+		t.Fatalf("Encode err = %v, want ErrTooLarge", err)
+	}
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{"max+1", []byte{0x01, 0x00, 0x40, 0x00}}, //MaxMessageLen+1
+		{"max uint32", []byte{0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(tt.header))
+			if !errors.Is(err, ErrTooLarge) {
+				t.Fatalf("Decode err = %v, want ErrTooLarge", err)
+			}
+		})
+	}
+}
+
+//shorten 消息太长时只打印开头
+func shorten(s string) string {
+	if len(s) > 32 {
+		return s[:32] + "..."
+	}
+	return s
+}
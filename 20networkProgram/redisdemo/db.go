@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//内存中的数据库，只有一个db（db 0），所有连接的命令都在锁中执行，和redis单线程执行命令的效果一样
+
+//entry 一个key的值，value是string或者zset
+type entry struct {
+	value    interface{}
+	expireAt time.Time //零值表示永不过期
+}
+
+//zset 有序集合，member到score的映射，查询时再排序，数据量小的时候足够用
+type zset map[string]float64
+
+//db 数据库
+type db struct {
+	mu   sync.Mutex
+	data map[string]*entry
+}
+
+//newDB ...构造函数
+func newDB() *db {
+	return &db{
+		data: make(map[string]*entry),
+	}
+}
+
+//lookup 获取未过期的key，已过期的key在这里删除（惰性删除），调用方需持有锁
+func (d *db) lookup(key string) *entry {
+	e, ok := d.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		delete(d.data, key)
+		return nil
+	}
+	return e
+}
+
+//sweep 定期删除已过期的key，防止过期后没有再访问的key一直占用内存
+func (d *db) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			for key := range d.data {
+				d.lookup(key)
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+//command 命令，arity为参数个数（包括命令名），负数表示至少-arity个
+type command struct {
+	arity int
+	fn    func(d *db, args []string, r reply)
+}
+
+//支持的命令，命令名不区分大小写
+var commands = map[string]command{
+	"ping":          {-1, cmdPing},
+	"echo":          {2, cmdEcho},
+	"select":        {2, cmdSelect},
+	"command":       {-1, cmdCommand},
+	"flushdb":       {-1, cmdFlushDB},
+	"get":           {2, cmdGet},
+	"set":           {-3, cmdSet},
+	"del":           {-2, cmdDel},
+	"exists":        {-2, cmdExists},
+	"expire":        {3, cmdExpire},
+	"ttl":           {2, cmdTTL},
+	"zadd":          {-4, cmdZAdd},
+	"zincrby":       {4, cmdZIncrBy},
+	"zscore":        {3, cmdZScore},
+	"zcard":         {2, cmdZCard},
+	"zrange":        {-4, cmdZRange(false)},
+	"zrevrange":     {-4, cmdZRange(true)},
+	"zrangebyscore": {-4, cmdZRangeByScore},
+}
+
+//execute 执行一条命令
+func (d *db) execute(args []string, r reply) {
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		r.error("ERR unknown command '" + args[0] + "'")
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		r.error("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cmd.fn(d, args, r)
+}
+
+const (
+	errWrongType = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	errSyntax    = "ERR syntax error"
+	errNotInt    = "ERR value is not an integer or out of range"
+	errNotFloat  = "ERR value is not a valid float"
+)
+
+func cmdPing(d *db, args []string, r reply) {
+	if len(args) > 1 {
+		r.bulk(args[1])
+		return
+	}
+	r.simple("PONG")
+}
+
+func cmdEcho(d *db, args []string, r reply) {
+	r.bulk(args[1])
+}
+
+//cmdSelect 只支持db 0
+func cmdSelect(d *db, args []string, r reply) {
+	if args[1] != "0" {
+		r.error("ERR DB index is out of range")
+		return
+	}
+	r.simple("OK")
+}
+
+//cmdCommand redis-cli启动时会发送COMMAND DOCS，回复空数组即可
+func cmdCommand(d *db, args []string, r reply) {
+	r.array(nil)
+}
+
+func cmdFlushDB(d *db, args []string, r reply) {
+	d.data = make(map[string]*entry)
+	r.simple("OK")
+}
+
+func cmdGet(d *db, args []string, r reply) {
+	e := d.lookup(args[1])
+	if e == nil {
+		r.null()
+		return
+	}
+	s, ok := e.value.(string)
+	if !ok {
+		r.error(errWrongType)
+		return
+	}
+	r.bulk(s)
+}
+
+//cmdSet SET key value [EX seconds|PX milliseconds|KEEPTTL] [NX|XX]
+func cmdSet(d *db, args []string, r reply) {
+	key, value := args[1], args[2]
+	var expireAt time.Time
+	var nx, xx, keepTTL bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(args[i]); opt {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "keepttl":
+			keepTTL = true
+		case "ex", "px":
+			if i+1 >= len(args) || !expireAt.IsZero() {
+				r.error(errSyntax)
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				r.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if opt == "px" {
+				unit = time.Millisecond
+			}
+			expireAt = time.Now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			r.error(errSyntax)
+			return
+		}
+	}
+	if (nx && xx) || (keepTTL && !expireAt.IsZero()) {
+		r.error(errSyntax)
+		return
+	}
+	old := d.lookup(key)
+	if (nx && old != nil) || (xx && old == nil) {
+		r.null()
+		return
+	}
+	if keepTTL && old != nil {
+		expireAt = old.expireAt
+	}
+	//SET会覆盖任何类型的值
+	d.data[key] = &entry{value: value, expireAt: expireAt}
+	r.simple("OK")
+}
+
+func cmdDel(d *db, args []string, r reply) {
+	n := 0
+	for _, key := range args[1:] {
+		if d.lookup(key) != nil {
+			delete(d.data, key)
+			n++
+		}
+	}
+	r.integer(n)
+}
+
+//cmdExists 同一个key出现多次时计算多次
+func cmdExists(d *db, args []string, r reply) {
+	n := 0
+	for _, key := range args[1:] {
+		if d.lookup(key) != nil {
+			n++
+		}
+	}
+	r.integer(n)
+}
+
+func cmdExpire(d *db, args []string, r reply) {
+	seconds, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		r.error(errNotInt)
+		return
+	}
+	e := d.lookup(args[1])
+	if e == nil {
+		r.integer(0)
+		return
+	}
+	if seconds <= 0 {
+		delete(d.data, args[1])
+	} else {
+		e.expireAt = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	r.integer(1)
+}
+
+//cmdTTL key不存在返回-2，没有过期时间返回-1
+func cmdTTL(d *db, args []string, r reply) {
+	e := d.lookup(args[1])
+	switch {
+	case e == nil:
+		r.integer(-2)
+	case e.expireAt.IsZero():
+		r.integer(-1)
+	default:
+		r.integer(int((time.Until(e.expireAt) + 500*time.Millisecond) / time.Second)) //和redis一样四舍五入
+	}
+}
+
+//lookupZSet 获取有序集合，key不存在时create为true则新建，类型不对时回复错误并返回false
+func (d *db) lookupZSet(key string, create bool, r reply) (zset, bool) {
+	e := d.lookup(key)
+	if e == nil {
+		if !create {
+			return nil, true
+		}
+		z := make(zset)
+		d.data[key] = &entry{value: z}
+		return z, true
+	}
+	z, ok := e.value.(zset)
+	if !ok {
+		r.error(errWrongType)
+		return nil, false
+	}
+	return z, true
+}
+
+//parseScore 解析分数，支持inf、+inf、-inf
+func parseScore(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+//formatScore 格式化分数，整数不使用科学计数法
+func formatScore(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case f == math.Trunc(f) && math.Abs(f) < 1e17:
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+//cmdZAdd ZADD key [NX|XX] [CH] score member [score member ...]
+func cmdZAdd(d *db, args []string, r reply) {
+	var nx, xx, ch bool
+	i := 2
+options: //选项在分数和成员之前
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "ch":
+			ch = true
+		default:
+			break options
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) {
+		r.error(errSyntax)
+		return
+	}
+	//先检查所有分数，有一个不合法时整个命令都不执行
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseScore(pairs[j])
+		if !ok {
+			r.error(errNotFloat)
+			return
+		}
+		scores = append(scores, score)
+	}
+	z, ok := d.lookupZSet(args[1], !xx, r)
+	if !ok {
+		return
+	}
+	added, changed := 0, 0
+	for j, score := range scores {
+		member := pairs[j*2+1]
+		old, exists := z[member]
+		switch {
+		case exists && nx, !exists && xx:
+			continue
+		case !exists:
+			added++
+		case old != score:
+			changed++
+		default:
+			continue
+		}
+		z[member] = score
+	}
+	if ch {
+		r.integer(added + changed)
+		return
+	}
+	r.integer(added)
+}
+
+//cmdZIncrBy ZINCRBY key increment member
+func cmdZIncrBy(d *db, args []string, r reply) {
+	incr, ok := parseScore(args[2])
+	if !ok {
+		r.error(errNotFloat)
+		return
+	}
+	z, ok := d.lookupZSet(args[1], true, r)
+	if !ok {
+		return
+	}
+	score := z[args[3]] + incr
+	if math.IsNaN(score) {
+		r.error("ERR resulting score is not a number (NaN)")
+		return
+	}
+	z[args[3]] = score
+	r.bulk(formatScore(score))
+}
+
+func cmdZScore(d *db, args []string, r reply) {
+	z, ok := d.lookupZSet(args[1], false, r)
+	if !ok {
+		return
+	}
+	score, exists := z[args[2]]
+	if !exists {
+		r.null()
+		return
+	}
+	r.bulk(formatScore(score))
+}
+
+func cmdZCard(d *db, args []string, r reply) {
+	z, ok := d.lookupZSet(args[1], false, r)
+	if ok {
+		r.integer(len(z))
+	}
+}
+
+//zitem 有序集合中的一个成员
+type zitem struct {
+	member string
+	score  float64
+}
+
+//sorted 按分数从小到大排序，分数相同时按成员的字典序排序
+func (z zset) sorted() []zitem {
+	items := make([]zitem, 0, len(z))
+	for m, s := range z {
+		items = append(items, zitem{member: m, score: s})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].score != items[j].score {
+			return items[i].score < items[j].score
+		}
+		return items[i].member < items[j].member
+	})
+	return items
+}
+
+//replyItems 回复成员列表，withScores为true时每个成员后面跟着分数
+func replyItems(items []zitem, withScores bool, r reply) {
+	ret := make([]string, 0, len(items)*2)
+	for _, item := range items {
+		ret = append(ret, item.member)
+		if withScores {
+			ret = append(ret, formatScore(item.score))
+		}
+	}
+	r.array(ret)
+}
+
+//cmdZRange ZRANGE/ZREVRANGE key start stop [WITHSCORES]，start和stop是下标，负数表示从末尾开始数
+func cmdZRange(reverse bool) func(d *db, args []string, r reply) {
+	return func(d *db, args []string, r reply) {
+		start, err1 := strconv.Atoi(args[2])
+		stop, err2 := strconv.Atoi(args[3])
+		if err1 != nil || err2 != nil {
+			r.error(errNotInt)
+			return
+		}
+		withScores := false
+		if len(args) == 5 && strings.ToLower(args[4]) == "withscores" {
+			withScores = true
+		} else if len(args) > 4 {
+			r.error(errSyntax)
+			return
+		}
+		z, ok := d.lookupZSet(args[1], false, r)
+		if !ok {
+			return
+		}
+		items := z.sorted()
+		if reverse {
+			for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+				items[i], items[j] = items[j], items[i]
+			}
+		}
+		n := len(items)
+		if start < 0 {
+			start += n
+		}
+		if stop < 0 {
+			stop += n
+		}
+		if start < 0 {
+			start = 0
+		}
+		if stop >= n {
+			stop = n - 1
+		}
+		if start > stop || start >= n {
+			r.array(nil)
+			return
+		}
+		replyItems(items[start:stop+1], withScores, r)
+	}
+}
+
+//scoreBound 分数范围的一端，(开头表示不包含
+type scoreBound struct {
+	value     float64
+	exclusive bool
+}
+
+func parseBound(s string) (scoreBound, bool) {
+	var b scoreBound
+	if strings.HasPrefix(s, "(") {
+		b.exclusive = true
+		s = s[1:]
+	}
+	var ok bool
+	b.value, ok = parseScore(s)
+	return b, ok
+}
+
+func (b scoreBound) lessOrEqual(score float64) bool { //b <= score
+	if b.exclusive {
+		return b.value < score
+	}
+	return b.value <= score
+}
+
+func (b scoreBound) greaterOrEqual(score float64) bool { //b >= score
+	if b.exclusive {
+		return b.value > score
+	}
+	return b.value >= score
+}
+
+//cmdZRangeByScore ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func cmdZRangeByScore(d *db, args []string, r reply) {
+	min, ok1 := parseBound(args[2])
+	max, ok2 := parseBound(args[3])
+	if !ok1 || !ok2 {
+		r.error("ERR min or max is not a float")
+		return
+	}
+	withScores := false
+	offset, count := 0, -1
+	for i := 4; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "withscores":
+			withScores = true
+		case "limit":
+			if i+2 >= len(args) {
+				r.error(errSyntax)
+				return
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(args[i+1])
+			count, err2 = strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				r.error(errNotInt)
+				return
+			}
+			i += 2
+		default:
+			r.error(errSyntax)
+			return
+		}
+	}
+	z, ok := d.lookupZSet(args[1], false, r)
+	if !ok {
+		return
+	}
+	items := make([]zitem, 0)
+	for _, item := range z.sorted() {
+		if min.lessOrEqual(item.score) && max.greaterOrEqual(item.score) {
+			items = append(items, item)
+		}
+	}
+	if offset < 0 || offset >= len(items) {
+		items = items[:0]
+	} else {
+		items = items[offset:]
+		if count >= 0 && count < len(items) {
+			items = items[:count]
+		}
+	}
+	replyItems(items, withScores, r)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/tcpserver"
+)
+
+// 兼容RESP2协议的内存版redis，在tcpdemo的accept/process循环的基础上实现，
+//默认监听127.0.0.1:6379，31db_redis中的GetsetDemo和ZsetDemo不用修改就可以连接
+
+var (
+	addr            = flag.String("addr", "127.0.0.1:6379", "监听的地址")
+	maxConns        = flag.Int("max-conns", 1000, "最大连接数，0表示不限制")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "关闭时等待连接处理完的最长时间")
+)
+
+//process 处理一个连接：依次读取命令并执行，客户端一次发送多条命令（pipeline）时，全部执行完再一起发送回复
+func process(d *db) tcpserver.Handler {
+	return func(ctx context.Context, conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		writer := bufio.NewWriter(conn)
+		r := reply{w: writer}
+		for {
+			//pipeline中的命令都执行完后才处于两条命令之间
+			if reader.Buffered() == 0 {
+				tcpserver.Idle(conn)
+			}
+			args, err := readCommand(reader)
+			if err != nil {
+				if errors.Is(err, errProtocol) {
+					r.error("ERR " + err.Error())
+					writer.Flush()
+				} else if err != io.EOF && ctx.Err() == nil {
+					fmt.Printf("read from %s failed, err:%v\n", conn.RemoteAddr(), err)
+				}
+				return
+			}
+			if len(args) == 0 { //空行
+				continue
+			}
+			if strings.ToLower(args[0]) == "quit" {
+				r.simple("OK")
+				writer.Flush()
+				return
+			}
+			d.execute(args, r)
+			//缓冲区中没有后续的命令时才发送回复
+			if reader.Buffered() == 0 {
+				if err := writer.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	d := newDB()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.sweep(ctx, time.Second)
+
+	srv := &tcpserver.Server{
+		Addr:     *addr,
+		Handler:  process(d),
+		MaxConns: *maxConns,
+	}
+	stopped := make(chan struct{})
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigChan
+		fmt.Printf("received %v, shutting down...\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Printf("shutdown failed, err:%v\n", err)
+		}
+		close(stopped)
+	}()
+
+	fmt.Printf("redis server listening on %s\n", *addr)
+	if err := srv.Serve(ctx); err != tcpserver.ErrServerClosed {
+		fmt.Printf("serve failed, err:%v\n", err)
+		return
+	}
+	<-stopped
+	fmt.Println("server stopped")
+}
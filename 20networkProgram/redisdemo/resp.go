@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//RESP2协议（REdis Serialization Protocol）：
+//客户端发送的命令是由批量字符串组成的数组，如 *2\r\n$3\r\nGET\r\n$3\r\nkey\r\n，
+//也支持telnet中直接输入的内联命令，如 GET key\r\n
+//服务端的回复按第一个字节区分类型：+简单字符串、-错误、:整数、$批量字符串（$-1表示nil）、*数组
+
+const (
+	maxBulkLen  = 64 << 20 //单个参数的最大长度
+	maxArgCount = 1 << 20  //单个命令的最大参数个数
+)
+
+//errProtocol 客户端发送的数据不符合协议，回复错误后关闭连接
+var errProtocol = errors.New("Protocol error")
+
+//readLine 读取一行，去掉结尾的\r\n
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(line[:len(line)-1], "\r"), nil
+}
+
+//readLength 解析*或者$后面的长度
+func readLength(line string, max int) (int, error) {
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < -1 || n > max {
+		return 0, fmt.Errorf("%w: invalid length %q", errProtocol, line)
+	}
+	return n, nil
+}
+
+//readCommand 读取一条命令，返回命令名和参数
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, nil
+	}
+	if line[0] != '*' { //内联命令
+		return strings.Fields(line), nil
+	}
+	//*-1表示nil数组，只会出现在回复中，命令不能是nil数组
+	n, err := readLength(line, maxArgCount)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: invalid multibulk length %q", errProtocol, line)
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+		size, err := readLength(line, maxBulkLen)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("%w: invalid bulk length %q", errProtocol, line)
+		}
+		//批量字符串按长度读取，内容中可以包含\r\n
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+//reply 写入回复的各种类型
+type reply struct {
+	w *bufio.Writer
+}
+
+func (r reply) simple(s string) {
+	r.w.WriteString("+" + s + "\r\n")
+}
+
+func (r reply) error(s string) {
+	r.w.WriteString("-" + s + "\r\n")
+}
+
+func (r reply) integer(n int) {
+	r.w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func (r reply) bulk(s string) {
+	r.w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (r reply) null() {
+	r.w.WriteString("$-1\r\n")
+}
+
+//array 写入由批量字符串组成的数组
+func (r reply) array(items []string) {
+	r.w.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, s := range items {
+		r.bulk(s)
+	}
+}
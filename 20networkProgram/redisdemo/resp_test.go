@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"array", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", []string{"GET", "key"}},
+		{"bulk with crlf", "*2\r\n$4\r\nECHO\r\n$4\r\na\r\nb\r\n", []string{"ECHO", "a\r\nb"}},
+		{"empty array", "*0\r\n", []string{}},
+		{"inline", "SET key value\r\n", []string{"SET", "key", "value"}},
+		{"empty line", "\r\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCommand(bufio.NewReader(strings.NewReader(tt.input)))
+			if err != nil {
+				t.Fatalf("readCommand(%q) failed, err:%v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("readCommand(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCommandProtocolError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"negative array length", "*-1\r\n"},
+		{"very negative array length", "*-100\r\n"},
+		{"oversized array length", "*" + strconv.Itoa(maxArgCount+1) + "\r\n"},
+		{"invalid array length", "*abc\r\n"},
+		{"negative bulk length", "*1\r\n$-1\r\n"},
+		{"very negative bulk length", "*1\r\n$-100\r\n"},
+		{"oversized bulk length", "*1\r\n$" + strconv.Itoa(maxBulkLen+1) + "\r\n"},
+		{"missing dollar", "*1\r\nGET\r\n"},
+		{"bulk not terminated", "*1\r\n$3\r\nGETxx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readCommand(bufio.NewReader(strings.NewReader(tt.input)))
+			if !errors.Is(err, errProtocol) {
+				t.Fatalf("readCommand(%q) err = %v, want %v", tt.input, err, errProtocol)
+			}
+		})
+	}
+}
+
+func TestReadCommandTruncated(t *testing.T) {
+	for _, input := range []string{"*2\r\n$3\r\nGET\r\n", "*1\r\n$3\r\nGE", "*1"} {
+		_, err := readCommand(bufio.NewReader(strings.NewReader(input)))
+		if err != io.ErrUnexpectedEOF && err != io.EOF {
+			t.Fatalf("readCommand(%q) err = %v, want EOF", input, err)
+		}
+	}
+}
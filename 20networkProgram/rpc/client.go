@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/proto"
+)
+
+//ErrShutdown 连接已关闭
+var ErrShutdown = errors.New("rpc: connection is shut down")
+
+//ServerError 服务端返回的错误
+type ServerError string
+
+func (e ServerError) Error() string {
+	return string(e)
+}
+
+//Client RPC客户端，可以在多个goroutine中同时调用Call
+type Client struct {
+	conn  net.Conn
+	codec Codec
+
+	writeMu sync.Mutex //多个调用会同时写连接
+
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]chan *response //等待响应的调用
+	err     error                     //连接出错后所有调用都返回该错误
+}
+
+//Dial 连接服务端
+func Dial(addr string, codec Codec) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(conn, codec)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+//NewClient 在已经建立的连接上创建客户端，比如TLS连接
+func NewClient(conn net.Conn, codec Codec) (*Client, error) {
+	c := &Client{
+		conn:    conn,
+		codec:   codec,
+		pending: make(map[uint64]chan *response),
+	}
+	//第一条消息告诉服务端使用的编码方式
+	if err := c.write([]byte(codec.Name())); err != nil {
+		return nil, err
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *Client) write(b []byte) error {
+	pkg, err := proto.Encode(string(b))
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.conn.Write(pkg)
+	return err
+}
+
+//readLoop 读取响应并交给对应的调用，连接出错时结束所有等待中的调用
+func (c *Client) readLoop() {
+	reader := bufio.NewReader(c.conn)
+	var err error
+	for {
+		var data string
+		if data, err = proto.Decode(reader); err != nil {
+			break
+		}
+		resp := new(response)
+		if err = c.codec.Unmarshal([]byte(data), resp); err != nil {
+			break
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+		if ok { //调用已经超时返回时丢弃响应
+			ch <- resp
+		}
+	}
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrShutdown
+	}
+	for id, ch := range c.pending {
+		delete(c.pending, id)
+		close(ch)
+	}
+	c.mu.Unlock()
+	c.conn.Close()
+}
+
+//Call 调用服务端的方法，结果解码到resp中，resp必须是指针
+//ctx有超时时间时会传给服务端，ctx结束时不再等待响应，返回ctx.Err()
+func (c *Client) Call(ctx context.Context, method string, req, resp interface{}) error {
+	body, err := c.codec.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r := &request{Method: method, Body: body}
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Timeout = time.Until(deadline)
+		if r.Timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+
+	ch := make(chan *response, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.seq++
+	r.ID = c.seq
+	c.pending[r.ID] = ch
+	c.mu.Unlock()
+
+	b, err := c.codec.Marshal(r)
+	if err == nil {
+		err = c.write(b)
+	}
+	if err != nil {
+		c.removePending(r.ID)
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.removePending(r.ID)
+		return ctx.Err()
+	case res, ok := <-ch:
+		if !ok {
+			return ErrShutdown
+		}
+		if res.Error != "" {
+			return ServerError(res.Error)
+		}
+		if resp == nil {
+			return nil
+		}
+		return c.codec.Unmarshal(res.Body, resp)
+	}
+}
+
+func (c *Client) removePending(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+//Close 关闭连接，等待中的调用返回ErrShutdown
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrShutdown
+	}
+	c.mu.Unlock()
+	return c.conn.Close()
+}
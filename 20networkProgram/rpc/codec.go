@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//Codec 请求和响应的编码方式，客户端连接后发送的第一条消息是编码方式的名字，之后双方都使用该编码
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+//支持的编码方式
+var (
+	JSON Codec = jsonCodec{}
+	Gob  Codec = gobCodec{}
+)
+
+//codecByName 根据名字获取编码方式
+func codecByName(name string) (Codec, error) {
+	for _, c := range []Codec{JSON, Gob} {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("rpc: unknown codec %q", name)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+//gobCodec 每条消息使用单独的Encoder，消息之间互不依赖，可以并发编码
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+//request 一次调用，Body是用同一个Codec编码后的参数
+type request struct {
+	ID      uint64
+	Method  string
+	Timeout time.Duration //客户端剩余的超时时间，0表示不限制，用时长而不是时间点，避免两台机器的时间不一致
+	Body    []byte
+}
+
+//response 调用的结果，Error不为空时表示调用失败
+type response struct {
+	ID    uint64
+	Error string
+	Body  []byte
+}
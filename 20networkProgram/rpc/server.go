@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/proto"
+	"github.com/Moqqll/02goLearning/20networkProgram/tcpserver"
+)
+
+//基于tcpdemo的连接处理实现的简单RPC：
+//消息格式使用proto解决黏包问题，每个连接上可以同时有多个调用，用请求ID对应请求和响应，
+//每个调用在单独的goroutine中执行，客户端的超时时间会传给服务端，作为方法的ctx的超时时间
+
+//写一条响应的超时时间，客户端不再读取时不会一直阻塞
+const writeTimeout = 10 * time.Second
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+//method 注册的方法
+type method struct {
+	fn      reflect.Value
+	reqType reflect.Type
+}
+
+//Server RPC服务端
+type Server struct {
+	mu      sync.RWMutex
+	methods map[string]*method
+}
+
+//NewServer ...构造函数
+func NewServer() *Server {
+	return &Server{
+		methods: make(map[string]*method),
+	}
+}
+
+//Register 注册方法，fn的类型必须是func(ctx context.Context, req T) (R, error)，T和R要能被Codec编码
+func (s *Server) Register(name string, fn interface{}) error {
+	if fn == nil {
+		return fmt.Errorf("rpc: method %s is nil", name)
+	}
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 2 ||
+		t.In(0) != typeOfContext || t.Out(1) != typeOfError {
+		return fmt.Errorf("rpc: method %s has wrong type %v, want func(context.Context, T) (R, error)", name, t)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.methods[name]; ok {
+		return fmt.Errorf("rpc: method %s already registered", name)
+	}
+	s.methods[name] = &method{fn: v, reqType: t.In(1)}
+	return nil
+}
+
+//ServeConn 处理一个连接，可以直接作为tcpserver.Handler使用
+//连接关闭时取消正在执行的调用；服务端关闭时不再读取新的请求，等正在执行的调用返回后再关闭连接
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	//第一条消息是编码方式
+	name, err := proto.Decode(reader)
+	if err != nil {
+		return
+	}
+	codec, err := codecByName(name)
+	if err != nil {
+		fmt.Printf("%s: %v\n", conn.RemoteAddr(), err)
+		return
+	}
+
+	//调用的ctx不从ctx派生，服务端关闭时让正在执行的调用执行完
+	callCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	var writeMu sync.Mutex //多个调用的响应会同时写连接
+	broken := false        //写失败后连接已经关闭，不再写后面的响应
+	for {
+		//请求交给单独的goroutine执行后就可以读取下一个请求，缓冲区中没有下一个请求的数据时连接处于两条消息之间
+		if reader.Buffered() == 0 {
+			tcpserver.Idle(conn)
+		}
+		data, err := proto.Decode(reader)
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				fmt.Printf("read from %s failed, err:%v\n", conn.RemoteAddr(), err)
+			}
+			if ctx.Err() == nil { //客户端断开，没有必要继续执行
+				cancel()
+			}
+			wg.Wait()
+			return
+		}
+		req := new(request)
+		if err := codec.Unmarshal([]byte(data), req); err != nil {
+			fmt.Printf("decode request from %s failed, err:%v\n", conn.RemoteAddr(), err)
+			cancel()
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := s.call(callCtx, codec, req)
+			b, err := codec.Marshal(resp)
+			if err == nil {
+				b, err = proto.Encode(string(b))
+			}
+			if err != nil {
+				fmt.Printf("encode response failed, err:%v\n", err)
+				return
+			}
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if broken {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := conn.Write(b); err != nil {
+				//关闭连接让读取返回错误，取消其它正在执行的调用
+				fmt.Printf("write to %s failed, err:%v\n", conn.RemoteAddr(), err)
+				broken = true
+				conn.Close()
+			}
+		}()
+	}
+}
+
+//call 执行一次调用
+func (s *Server) call(ctx context.Context, codec Codec, req *request) (resp *response) {
+	resp = &response{ID: req.ID}
+	s.mu.RLock()
+	m, ok := s.methods[req.Method]
+	s.mu.RUnlock()
+	if !ok {
+		resp.Error = fmt.Sprintf("rpc: method %s not found", req.Method)
+		return resp
+	}
+
+	//参数是指针时解码到新建的对象中，否则解码到新建的对象后取值
+	var arg reflect.Value
+	if m.reqType.Kind() == reflect.Ptr {
+		arg = reflect.New(m.reqType.Elem())
+		if err := codec.Unmarshal(req.Body, arg.Interface()); err != nil {
+			resp.Error = fmt.Sprintf("rpc: decode request of %s failed, err:%v", req.Method, err)
+			return resp
+		}
+	} else {
+		ptr := reflect.New(m.reqType)
+		if err := codec.Unmarshal(req.Body, ptr.Interface()); err != nil {
+			resp.Error = fmt.Sprintf("rpc: decode request of %s failed, err:%v", req.Method, err)
+			return resp
+		}
+		arg = ptr.Elem()
+	}
+
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+	//方法panic时返回错误，不影响其他调用
+	defer func() {
+		if p := recover(); p != nil {
+			resp = &response{ID: req.ID, Error: fmt.Sprintf("rpc: method %s panic: %v", req.Method, p)}
+		}
+	}()
+	out := m.fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+	if err, _ := out[1].Interface().(error); err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	b, err := codec.Marshal(out[0].Interface())
+	if err != nil {
+		resp.Error = fmt.Sprintf("rpc: encode response of %s failed, err:%v", req.Method, err)
+		return resp
+	}
+	resp.Body = b
+	return resp
+}
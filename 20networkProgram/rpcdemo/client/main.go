@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/rpc"
+)
+
+// RPC client端，演示普通调用、返回错误、同一个连接上的并发调用和超时
+
+var (
+	addr      = flag.String("addr", "127.0.0.1:2002", "服务端地址")
+	codecName = flag.String("codec", "json", "编码方式：json或gob")
+)
+
+//Args 和服务端的Args字段一致即可
+type Args struct {
+	A, B int
+}
+
+//Quotient 除法的结果
+type Quotient struct {
+	Quo, Rem int
+}
+
+func main() {
+	flag.Parse()
+	codec := rpc.JSON
+	if *codecName == "gob" {
+		codec = rpc.Gob
+	}
+	client, err := rpc.Dial(*addr, codec)
+	if err != nil {
+		fmt.Printf("dial failed, err:%v\n", err)
+		return
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	var sum int
+	if err := client.Call(ctx, "Arith.Add", Args{A: 7, B: 8}, &sum); err != nil {
+		fmt.Printf("call Arith.Add failed, err:%v\n", err)
+	} else {
+		fmt.Printf("Arith.Add 7+8=%d\n", sum)
+	}
+
+	var q Quotient
+	if err := client.Call(ctx, "Arith.Divide", Args{A: 17, B: 5}, &q); err != nil {
+		fmt.Printf("call Arith.Divide failed, err:%v\n", err)
+	} else {
+		fmt.Printf("Arith.Divide 17/5=%d...%d\n", q.Quo, q.Rem)
+	}
+	err = client.Call(ctx, "Arith.Divide", Args{A: 1, B: 0}, &q)
+	fmt.Printf("Arith.Divide 1/0 err:%v\n", err)
+	err = client.Call(ctx, "NoSuchMethod", struct{}{}, nil)
+	fmt.Printf("NoSuchMethod err:%v\n", err)
+
+	//同一个连接上同时发起多个调用，耗时短的先返回
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, d := range []time.Duration{300 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond} {
+		wg.Add(1)
+		go func(d time.Duration) {
+			defer wg.Done()
+			var reply string
+			if err := client.Call(ctx, "Sleep", d, &reply); err != nil {
+				fmt.Printf("call Sleep failed, err:%v\n", err)
+				return
+			}
+			fmt.Printf("Sleep(%v) reply:%s after %v\n", d, reply, time.Since(start).Round(time.Millisecond))
+		}(d)
+	}
+	wg.Wait()
+
+	//超时时间会传给服务端，服务端的方法也会提前返回
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	var reply string
+	err = client.Call(timeoutCtx, "Sleep", time.Second, &reply)
+	fmt.Printf("Sleep(1s) with 100ms timeout err:%v\n", err)
+
+	if err := client.Call(ctx, "Echo", "连接在超时后还可以继续使用", &reply); err != nil {
+		fmt.Printf("call Echo failed, err:%v\n", err)
+		return
+	}
+	fmt.Printf("Echo reply:%s\n", reply)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/rpc"
+	"github.com/Moqqll/02goLearning/20networkProgram/tcpserver"
+)
+
+// RPC server端，在tcpdemo的基础上把回复ok换成按方法名分发调用
+
+var (
+	addr            = flag.String("addr", "127.0.0.1:2002", "监听的地址")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "关闭时等待调用执行完的最长时间")
+)
+
+//Args 算术运算的参数
+type Args struct {
+	A, B int
+}
+
+//Quotient 除法的结果
+type Quotient struct {
+	Quo, Rem int
+}
+
+func add(ctx context.Context, args Args) (int, error) {
+	return args.A + args.B, nil
+}
+
+func divide(ctx context.Context, args *Args) (*Quotient, error) {
+	if args.B == 0 {
+		return nil, errors.New("divide by zero")
+	}
+	return &Quotient{Quo: args.A / args.B, Rem: args.A % args.B}, nil
+}
+
+//sleep 模拟耗时的调用，超时或者客户端断开时提前返回
+func sleep(ctx context.Context, d time.Duration) (string, error) {
+	select {
+	case <-time.After(d):
+		return fmt.Sprintf("slept %v", d), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func main() {
+	flag.Parse()
+	server := rpc.NewServer()
+	for name, fn := range map[string]interface{}{
+		"Arith.Add":    add,
+		"Arith.Divide": divide,
+		"Sleep":        sleep,
+		"Echo": func(ctx context.Context, s string) (string, error) {
+			return s, nil
+		},
+	} {
+		if err := server.Register(name, fn); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	srv := &tcpserver.Server{
+		Addr:     *addr,
+		Handler:  server.ServeConn,
+		MaxConns: 100,
+	}
+	stopped := make(chan struct{})
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigChan
+		fmt.Printf("received %v, shutting down...\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Printf("shutdown failed, err:%v\n", err)
+		}
+		close(stopped)
+	}()
+
+	fmt.Printf("rpc server listening on %s\n", *addr)
+	if err := srv.Serve(context.Background()); err != tcpserver.ErrServerClosed {
+		fmt.Printf("serve failed, err:%v\n", err)
+		return
+	}
+	<-stopped
+	fmt.Println("server stopped")
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/proto"
+	"github.com/Moqqll/02goLearning/20networkProgram/tlsutil"
+)
+
+// tcpdemo服务端的压测工具：同时建立N个连接，每个连接发送M条消息并等待回复的ok，
+//统计吞吐量、错误数和延迟的分位数，结果以表格打印，也可以保存为json
+//服务端打印每条消息会拖慢处理速度，压测时用--quiet启动：
+//  go run ./tcpdemo --quiet --max-conns 1000
+//  go run ./tcpbench --conns 100 --messages 1000 --rate 20000
+
+var (
+	addr       = flag.String("addr", "127.0.0.1:2000", "服务端的地址")
+	conns      = flag.Int("conns", 10, "并发的连接数")
+	messages   = flag.Int("messages", 100, "每个连接发送的消息数")
+	rate       = flag.Float64("rate", 0, "所有连接加起来每秒发送的消息数，0表示不限制")
+	size       = flag.Int("size", 64, "每条消息的字节数")
+	timeout    = flag.Duration("timeout", 5*time.Second, "连接和等待回复的超时时间")
+	jsonFile   = flag.String("json", "", "把结果以json格式保存到文件，-表示打印到标准输出")
+	useTLS     = flag.Bool("tls", false, "使用TLS连接")
+	caFile     = flag.String("ca", "", "校验服务端证书的CA，默认使用系统的CA")
+	certFile   = flag.String("cert", "", "客户端证书，服务端开启双向认证时需要")
+	keyFile    = flag.String("key", "", "客户端证书的私钥")
+	serverName = flag.String("server-name", "", "校验服务端证书时使用的主机名，默认使用addr中的主机名")
+)
+
+//result 一个连接的压测结果，每个goroutine先记录自己的结果，结束后再加锁合并，避免每条消息都加锁
+type result struct {
+	sent      int
+	latencies []time.Duration
+	errors    map[string]int
+}
+
+func (r *result) fail(kind string) {
+	if r.errors == nil {
+		r.errors = make(map[string]int)
+	}
+	r.errors[kind]++
+}
+
+//classify 把错误归类，方便统计
+func classify(op string, err error) string {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return op + ": closed by server" //比如超过了服务端的最大连接数
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return op + ": timeout"
+	}
+	if strings.Contains(err.Error(), "connection reset") {
+		return op + ": connection reset"
+	}
+	if strings.Contains(err.Error(), "connection refused") {
+		return op + ": connection refused"
+	}
+	return op + ": other"
+}
+
+//bench 一个连接的压测：按interval的间隔发送消息，每条消息都等待回复后再发送下一条
+func bench(ctx context.Context, config *tls.Config, interval time.Duration, payload []byte) *result {
+	r := &result{}
+	dialer := &net.Dialer{Timeout: *timeout}
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", *addr, config)
+	} else {
+		conn, err = dialer.Dial("tcp", *addr)
+	}
+	if err != nil {
+		r.fail(classify("dial", err))
+		return r
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+	}
+	for i := 0; i < *messages; i++ {
+		if ticker != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+				return r
+			case <-ticker.C:
+			}
+		} else if ctx.Err() != nil {
+			return r
+		}
+		start := time.Now()
+		conn.SetDeadline(start.Add(*timeout))
+		r.sent++
+		if _, err := conn.Write(payload); err != nil {
+			r.fail(classify("write", err))
+			return r
+		}
+		reply, err := proto.Decode(reader)
+		if err != nil {
+			r.fail(classify("read", err))
+			return r
+		}
+		if reply != "ok" {
+			r.fail("unexpected reply")
+			continue
+		}
+		r.latencies = append(r.latencies, time.Since(start))
+	}
+	return r
+}
+
+//latencyStats 延迟的统计，单位是毫秒
+type latencyStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+//report 整个压测的结果
+type report struct {
+	Addr       string         `json:"addr"`
+	Conns      int            `json:"conns"`
+	Messages   int            `json:"messages_per_conn"`
+	Size       int            `json:"size"`
+	Rate       float64        `json:"target_rate"`
+	Duration   float64        `json:"duration_seconds"`
+	Sent       int            `json:"sent"`
+	Succeeded  int            `json:"succeeded"`
+	Throughput float64        `json:"throughput"` //每秒成功的消息数
+	Errors     map[string]int `json:"errors"`
+	Latency    latencyStats   `json:"latency_ms"`
+}
+
+//percentile 已经排好序的延迟中第p百分位的值（nearest-rank）
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newReport(total *result, elapsed time.Duration) *report {
+	rep := &report{
+		Addr:      *addr,
+		Conns:     *conns,
+		Messages:  *messages,
+		Size:      *size,
+		Rate:      *rate,
+		Duration:  elapsed.Seconds(),
+		Sent:      total.sent,
+		Succeeded: len(total.latencies),
+		Errors:    total.errors,
+	}
+	if rep.Errors == nil {
+		rep.Errors = map[string]int{}
+	}
+	if elapsed > 0 {
+		rep.Throughput = float64(rep.Succeeded) / elapsed.Seconds()
+	}
+	lat := total.latencies
+	if len(lat) > 0 {
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		var sum time.Duration
+		for _, d := range lat {
+			sum += d
+		}
+		rep.Latency = latencyStats{
+			Min:  ms(lat[0]),
+			Mean: ms(sum / time.Duration(len(lat))),
+			P50:  ms(percentile(lat, 50)),
+			P90:  ms(percentile(lat, 90)),
+			P99:  ms(percentile(lat, 99)),
+			Max:  ms(lat[len(lat)-1]),
+		}
+	}
+	return rep
+}
+
+//printTable 以表格的形式打印结果
+func (rep *report) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	target := "unlimited"
+	if rep.Rate > 0 {
+		target = fmt.Sprintf("%.0f msg/s", rep.Rate)
+	}
+	fmt.Fprintf(tw, "addr\t%s\n", rep.Addr)
+	fmt.Fprintf(tw, "conns x messages\t%d x %d (%d bytes, target %s)\n", rep.Conns, rep.Messages, rep.Size, target)
+	fmt.Fprintf(tw, "duration\t%.2fs\n", rep.Duration)
+	fmt.Fprintf(tw, "sent / succeeded\t%d / %d\n", rep.Sent, rep.Succeeded)
+	fmt.Fprintf(tw, "throughput\t%.1f msg/s\n", rep.Throughput)
+	kinds := make([]string, 0, len(rep.Errors))
+	for kind := range rep.Errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	if len(kinds) == 0 {
+		fmt.Fprintf(tw, "errors\t0\n")
+	}
+	for _, kind := range kinds {
+		fmt.Fprintf(tw, "errors\t%s: %d\n", kind, rep.Errors[kind])
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "latency(ms)\tmin\tmean\tp50\tp90\tp99\tmax\t")
+	l := rep.Latency
+	fmt.Fprintf(tw, "\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n", l.Min, l.Mean, l.P50, l.P90, l.P99, l.Max)
+	tw.Flush()
+}
+
+//writeJSON 把结果以json格式保存到文件，name为-时打印到标准输出
+func (rep *report) writeJSON(name string) error {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if name == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(name, b, 0644)
+}
+
+func main() {
+	flag.Parse()
+	if *conns <= 0 || *messages <= 0 || *size < 0 || *rate < 0 {
+		fmt.Println("conns and messages must be positive, size and rate must not be negative")
+		os.Exit(2)
+	}
+	var config *tls.Config
+	if *useTLS {
+		var err error
+		config, err = tlsutil.ClientConfig(*caFile, *certFile, *keyFile, *serverName)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	payload, err := proto.Encode(strings.Repeat("x", *size))
+	if err != nil {
+		fmt.Printf("encode msg failed, err:%v\n", err)
+		os.Exit(2)
+	}
+	//总的速率平分给每个连接
+	var interval time.Duration
+	if *rate > 0 {
+		interval = time.Duration(float64(time.Second) * float64(*conns) / *rate)
+	}
+
+	//收到SIGINT时提前结束，已经完成的部分照常统计
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		fmt.Println("interrupted, waiting for in-flight messages...")
+		cancel()
+	}()
+
+	var (
+		wg    sync.WaitGroup
+		lock  sync.Mutex
+		total = &result{errors: make(map[string]int)}
+	)
+	start := time.Now()
+	for i := 0; i < *conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := bench(ctx, config, interval, payload)
+			lock.Lock() //合并结果时加锁
+			total.sent += r.sent
+			total.latencies = append(total.latencies, r.latencies...)
+			for kind, n := range r.errors {
+				total.errors[kind] += n
+			}
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	rep := newReport(total, time.Since(start))
+
+	if *jsonFile == "-" {
+		if err := rep.writeJSON("-"); err != nil {
+			fmt.Printf("write json failed, err:%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	rep.printTable(os.Stdout)
+	if *jsonFile != "" {
+		if err := rep.writeJSON(*jsonFile); err != nil {
+			fmt.Printf("write json failed, err:%v\n", err)
+			os.Exit(1)
+		}
+	}
+}
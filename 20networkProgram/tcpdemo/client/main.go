@@ -1,20 +1,227 @@
 package main
 
 import (
+	"bufio"
+	"crypto/tls"
+	"flag"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/proto"
+	"github.com/Moqqll/02goLearning/20networkProgram/tlsutil"
 )
 
 // tcp/client/main.go
 
 // TCP client端
+//从标准输入按行读取消息发送给服务端，并打印服务端的回复，输入quit退出
+//连接断开时按指数退避自动重连，重连成功后重新发送没有发送成功的消息
+
+var (
+	addr       = flag.String("addr", "127.0.0.1:2000", "服务端地址")
+	maxBackoff = flag.Duration("max-backoff", 10*time.Second, "重连的最大间隔")
+	useTLS     = flag.Bool("tls", false, "使用TLS连接，指定了--ca时自动开启")
+	caFile     = flag.String("ca", "", "信任的CA证书，为空时使用系统的根证书")
+	certFile   = flag.String("cert", "", "客户端证书，服务端开启双向认证时需要")
+	keyFile    = flag.String("key", "", "客户端证书的私钥")
+	serverName = flag.String("server-name", "", "校验服务端证书时使用的域名，默认为--addr中的主机名")
+)
+
+//tlsConfig 不为nil时使用TLS连接
+var tlsConfig *tls.Config
+
+const (
+	minBackoff = 500 * time.Millisecond //重连的初始间隔
+	stableConn = time.Second            //连接保持超过该时间才重置重连间隔，防止服务端拒绝连接时不停重连
+	maxQueued  = 100                    //断开连接期间最多缓存的消息数
+)
+
+//readStdin 从标准输入按行读取消息发送到lines中，输入quit或标准输入关闭时关闭quit
+//断开连接期间没有人接收lines，消息先缓存在lines中，缓存满了就丢弃，不阻塞读取，保证随时可以输入quit退出
+func readStdin(lines chan<- string, quit chan<- struct{}) {
+	defer close(quit)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		text, err := reader.ReadString('\n')
+		text = strings.TrimRight(text, "\r\n")
+		if text == "quit" {
+			return
+		}
+		if text != "" {
+			select {
+			case lines <- text:
+			default:
+				fmt.Printf("not connected and %d messages queued, dropped: %s\n", maxQueued, text)
+			}
+		}
+		if err != nil { //标准输入关闭
+			return
+		}
+	}
+}
+
+//dial 连接服务端，失败时按指数退避重试，收到quit或者证书校验失败时返回nil
+//backoff为下一次重试前等待的时间，连接成功后不会重置，由调用方决定何时重置
+func dial(quit <-chan struct{}, backoff *time.Duration) net.Conn {
+	for {
+		conn, err := dialOnce()
+		if err == nil {
+			fmt.Printf("connected to %s\n", *addr)
+			return conn
+		}
+		if tlsutil.IsCertError(err) { //证书有问题时重试也不会成功
+			fmt.Printf("tls handshake failed, err:%s\n", tlsutil.Explain(err))
+			return nil
+		}
+		fmt.Printf("dial failed, err:%s, retry in %v\n", tlsutil.Explain(err), *backoff)
+		if !wait(quit, backoff) {
+			return nil
+		}
+	}
+}
+
+//dialOnce 建立TCP连接，开启TLS时同时完成握手
+func dialOnce() (net.Conn, error) {
+	if tlsConfig == nil {
+		return net.Dial("tcp", *addr)
+	}
+	return tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", *addr, tlsConfig)
+}
+
+//wait 等待backoff后将其翻倍，收到quit时返回false
+func wait(quit <-chan struct{}, backoff *time.Duration) bool {
+	select {
+	case <-quit:
+		return false
+	case <-time.After(*backoff):
+	}
+	*backoff *= 2
+	if *backoff > *maxBackoff {
+		*backoff = *maxBackoff
+	}
+	return true
+}
+
+//recv 读取并打印服务端的回复，连接断开时把错误保存到errp中并关闭done
+func recv(conn net.Conn, done chan<- struct{}, errp *error) {
+	defer close(done)
+	reader := bufio.NewReader(conn)
+	for {
+		reply, err := proto.Decode(reader)
+		if err == io.EOF {
+			fmt.Println("server closed the connection")
+			return
+		}
+		if err != nil {
+			fmt.Printf("recv reply failed, err:%s\n", tlsutil.Explain(err))
+			*errp = err
+			return
+		}
+		fmt.Printf("< %s\n", reply)
+	}
+}
+
+//send 发送lines中的消息，pending不为空时先发送pending
+//收到quit时把lines中缓存的消息发完后返回true，连接断开时返回false和没有发送成功的消息
+func send(conn net.Conn, pending string, lines <-chan string, quit <-chan struct{}, done <-chan struct{}) (bool, string) {
+	quitting := false
+	for {
+		if pending != "" {
+			b, err := proto.Encode(pending)
+			if err != nil {
+				fmt.Printf("encode msg failed, err:%v\n", err)
+				pending = ""
+				continue
+			}
+			if _, err := conn.Write(b); err != nil {
+				fmt.Printf("send msg failed, err:%v\n", err)
+				return false, pending
+			}
+			pending = ""
+		}
+		if quitting {
+			//readStdin关闭quit之前已经把quit前输入的消息都放进了lines
+			select {
+			case pending = <-lines:
+				continue
+			default:
+				return true, ""
+			}
+		}
+		select {
+		case <-quit:
+			quitting = true
+		case <-done:
+			return false, ""
+		case pending = <-lines:
+		}
+	}
+}
+
+//closeGracefully 先关闭写端，等服务端回复完已经发送的消息后再关闭连接
+func closeGracefully(conn net.Conn, done <-chan struct{}) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+		}
+	}
+	conn.Close()
+	<-done
+}
+
 func main() {
-	//与服务端建立连接
-	conn, err := net.Dial("tcp", "127.0.0.1:2000")
-	if err != nil {
-		fmt.Printf("dial failed, err%v\n", err)
-		return
+	flag.Parse()
+	if *useTLS || *caFile != "" {
+		var err error
+		tlsConfig, err = tlsutil.ClientConfig(*caFile, *certFile, *keyFile, *serverName)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 	}
-	//利用该连接进行数据的发送和接受
+	lines := make(chan string, maxQueued)
+	quit := make(chan struct{})
+	go readStdin(lines, quit)
 
+	var pending string
+	backoff := minBackoff
+	for {
+		//与服务端建立连接
+		conn := dial(quit, &backoff)
+		if conn == nil {
+			return
+		}
+		connected := time.Now()
+		//利用该连接进行数据的发送和接受
+		done := make(chan struct{})
+		var recvErr error
+		go recv(conn, done, &recvErr)
+		var exit bool
+		exit, pending = send(conn, pending, lines, quit, done)
+		if exit {
+			closeGracefully(conn, done)
+			return
+		}
+		conn.Close()
+		<-done //等待recv退出
+		//TLS1.3中服务端在客户端握手完成后才校验客户端证书，校验失败时在读取回复时才能发现
+		if recvErr != nil && tlsutil.IsCertError(recvErr) {
+			return
+		}
+		if time.Since(connected) >= stableConn {
+			backoff = minBackoff
+			fmt.Println("connection lost, reconnecting...")
+			continue
+		}
+		fmt.Printf("connection lost, reconnecting in %v...\n", backoff)
+		if !wait(quit, &backoff) {
+			return
+		}
+	}
 }
@@ -2,52 +2,118 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/proto"
+	"github.com/Moqqll/02goLearning/20networkProgram/tcpserver"
+	"github.com/Moqqll/02goLearning/20networkProgram/tlsutil"
 )
 
 // tcp/server/main.go
 
 // TCP server端
 
-//处理函数
-func process(conn net.Conn) {
+var (
+	addr            = flag.String("addr", "127.0.0.1:2000", "监听的地址")
+	maxConns        = flag.Int("max-conns", 100, "最大连接数，0表示不限制")
+	idleTimeout     = flag.Duration("idle-timeout", time.Minute, "连接的空闲超时时间，0表示不限制")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "关闭时等待连接处理完的最长时间")
+	certFile        = flag.String("cert", "", "服务端证书，指定后使用TLS")
+	keyFile         = flag.String("key", "", "服务端证书的私钥")
+	clientCAFile    = flag.String("client-ca", "", "签发客户端证书的CA，指定后开启双向认证")
+	quiet           = flag.Bool("quiet", false, "不打印收到的每条消息，压测时使用")
+)
+
+//处理函数，服务端关闭时ctx会被取消
+func process(ctx context.Context, conn net.Conn) {
 	defer conn.Close() //处理完后要关闭连接
+	//创建一个从当前tcp连接（conn）中进行读操作的对象reader
+	//reader中会缓存已经读到但还没处理的数据，所以整个连接只能使用同一个reader
+	reader := bufio.NewReader(conn)
 	//针对当前连接做数据的发送和接受操作
 	for {
-		//创建一个从当前tcp连接（conn）中进行读操作的对象reader
-		reader := bufio.NewReader(conn)
-		//声明一个128大小的字节类型数组buf
-		var buf [128]byte
-		//把数组buf转换为切片buf传入reader对象的Read方法中
-		//Read方法会返回一个n值（读取的字节长度）和一个错误信息
-		//并把读取到的字节数据存储切片buf中
-		n, err := reader.Read(buf[:])
+		//上一条消息已经回复完，缓冲区中也没有下一条消息时，连接处于两条消息之间，服务端关闭时可以直接打断读取
+		if reader.Buffered() == 0 {
+			tcpserver.Idle(conn)
+		}
+		//按照proto中的消息格式每次读取一条完整的消息，解决黏包问题
+		recv, err := proto.Decode(reader)
+		if err == io.EOF { //客户端关闭了连接
+			return
+		}
 		if err != nil { //错误处理
+			if ctx.Err() != nil { //服务端正在关闭，已经读到的消息都处理完了
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() { //连接空闲超时
+				fmt.Printf("%s idle timeout, closed\n", conn.RemoteAddr())
+				return
+			}
 			fmt.Printf("read from conn failed, err:%v\n", err)
-			break
+			return
+		}
+		if !*quiet {
+			fmt.Printf("接收到的数据：%d bytes %.64q\n", len(recv), recv)
+		}
+		//向客户端回复一个ok信息，回复同样需要编码
+		b, err := proto.Encode("ok")
+		if err != nil {
+			fmt.Printf("encode msg failed, err:%v\n", err)
+			return
+		}
+		if _, err := conn.Write(b); err != nil {
+			fmt.Printf("write to conn failed, err:%v\n", err)
+			return
 		}
-		recv := string(buf[:n]) //把切片buf中的数据转换为字符串conn
-		fmt.Printf("接收到的数据；%v\n", recv)
-		conn.Write("ok") //向客户端写一个ok信息
 	}
 }
 
 func main() {
-	//1、启动一个tcp监听
-	listen, err := net.Listen("tcp", "127.0.0.1:2000")
-	if err != nil {
-		fmt.Println("listen failed, err:%v\n", err)
-		return
+	flag.Parse()
+	srv := &tcpserver.Server{
+		Addr:        *addr,
+		Handler:     process,
+		MaxConns:    *maxConns,
+		IdleTimeout: *idleTimeout,
 	}
-	for {
-		//2、等待客户端来连接
-		conn, err := listen.Accept()
+	if *certFile != "" {
+		config, err := tlsutil.ServerConfig(*certFile, *keyFile, *clientCAFile)
 		if err != nil {
-			fmt.Printf("accept failed, err:%v\n", err)
-			continue
+			fmt.Println(err)
+			return
+		}
+		srv.TLSConfig = config
+	}
+
+	//收到SIGINT或SIGTERM时优雅关闭
+	stopped := make(chan struct{})
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigChan
+		fmt.Printf("received %v, shutting down...\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Printf("shutdown failed, err:%v\n", err)
 		}
-		//3、启动一个单独的goroutine去处理连接
-		go process(conn)
+		close(stopped)
+	}()
+
+	//启动tcp监听，每个连接启动一个单独的goroutine去处理
+	fmt.Printf("listening on %s\n", *addr)
+	if err := srv.Serve(context.Background()); err != tcpserver.ErrServerClosed {
+		fmt.Printf("serve failed, err:%v\n", err)
+		return
 	}
+	<-stopped //等待已有的连接处理完
+	fmt.Println("server stopped")
 }
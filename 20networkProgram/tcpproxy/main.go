@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/tcpserver"
+)
+
+// TCP代理（端口转发）：监听一个地址，把每个连接转发到上游，双向用io.Copy复制数据
+//有多个上游时轮询（round-robin）选择，定期检查上游是否可以连接，不健康的上游只在其它上游都连接失败时才会被选中
+//比如把29stdlib_context/ex中的client和server隔开：
+//  go run ./tcpproxy --listen 127.0.0.1:9001 --upstreams 127.0.0.1:9000
+
+var (
+	listen          = flag.String("listen", "127.0.0.1:9001", "监听的地址")
+	upstreams       = flag.String("upstreams", "127.0.0.1:9000", "上游地址，多个用逗号分隔")
+	dialTimeout     = flag.Duration("dial-timeout", 3*time.Second, "连接上游的超时时间")
+	healthInterval  = flag.Duration("health-interval", 5*time.Second, "健康检查的间隔，0表示不检查")
+	idleTimeout     = flag.Duration("idle-timeout", 5*time.Minute, "连接的空闲超时时间，两个方向都没有转发数据超过该时间时关闭连接，0表示不限制")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "关闭时等待连接处理完的最长时间")
+)
+
+//upstream 一个上游
+type upstream struct {
+	addr    string
+	healthy int32 //1表示健康，用atomic读写
+}
+
+func (u *upstream) isHealthy() bool {
+	return atomic.LoadInt32(&u.healthy) == 1
+}
+
+//setHealthy 设置健康状态，状态变化时打印日志
+func (u *upstream) setHealthy(healthy bool, reason error) {
+	var v int32
+	if healthy {
+		v = 1
+	}
+	if atomic.SwapInt32(&u.healthy, v) == v {
+		return
+	}
+	if healthy {
+		fmt.Printf("upstream %s is up\n", u.addr)
+	} else {
+		fmt.Printf("upstream %s is down, err:%v\n", u.addr, reason)
+	}
+}
+
+//dial 连接上游，并根据结果更新健康状态
+func (u *upstream) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", u.addr, *dialTimeout)
+	if err != nil {
+		u.setHealthy(false, err)
+		return nil, err
+	}
+	u.setHealthy(true, nil)
+	return conn, nil
+}
+
+//proxy 代理
+type proxy struct {
+	upstreams   []*upstream
+	next        uint32 //轮询的计数器
+	checkHealth bool   //是否定期检查上游，不检查时不健康的上游只能靠连接成功恢复，所以照常轮询
+}
+
+//newProxy ...构造函数，上游初始时都认为是健康的
+func newProxy(addrs []string, checkHealth bool) *proxy {
+	p := &proxy{checkHealth: checkHealth}
+	for _, addr := range addrs {
+		p.upstreams = append(p.upstreams, &upstream{addr: addr, healthy: 1})
+	}
+	return p
+}
+
+//dial 轮询选择一个健康的上游并连接，连接失败时把该上游标记为不健康并尝试下一个，
+//健康的上游都连接失败时再尝试不健康的上游，避免一次偶然的失败让代理一直拒绝客户端
+func (p *proxy) dial() (*upstream, net.Conn, error) {
+	n := uint32(len(p.upstreams))
+	start := atomic.AddUint32(&p.next, 1)
+	down := make([]*upstream, 0)
+	for i := uint32(0); i < n; i++ {
+		u := p.upstreams[(start+i)%n]
+		if p.checkHealth && !u.isHealthy() {
+			down = append(down, u)
+			continue
+		}
+		if conn, err := u.dial(); err == nil {
+			return u, conn, nil
+		}
+	}
+	for _, u := range down {
+		if conn, err := u.dial(); err == nil {
+			return u, conn, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no available upstream")
+}
+
+//healthCheck 定期连接每个上游，能连接上就认为是健康的
+func (p *proxy) healthCheck(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var wg sync.WaitGroup
+		for _, u := range p.upstreams {
+			wg.Add(1)
+			go func(u *upstream) {
+				defer wg.Done()
+				if conn, err := u.dial(); err == nil {
+					conn.Close()
+				}
+			}(u)
+		}
+		wg.Wait()
+	}
+}
+
+//closeWrite 关闭写端，让对端读到EOF，不支持时直接关闭连接
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok && cw.CloseWrite() == nil {
+		return
+	}
+	conn.Close()
+}
+
+//activity 记录一个代理连接最后一次转发数据的时间，两个方向共用，用atomic读写
+type activity struct {
+	last int64 //UnixNano
+}
+
+func (a *activity) touch() {
+	atomic.StoreInt64(&a.last, time.Now().UnixNano())
+}
+
+//idle 距离最后一次转发数据的时间
+func (a *activity) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&a.last)))
+}
+
+//forwardWriter 把从src读到的数据写到dst，每次写完后记录转发时间，并让src回到两条“消息”之间
+//代理不知道消息的边界，把每次转发完的数据都当作一条完整的消息，服务端关闭时下一次读取客户端会立即返回超时错误
+//src是上游连接时tcpserver.Idle什么都不做
+type forwardWriter struct {
+	dst net.Conn
+	src net.Conn
+	act *activity
+}
+
+func (w forwardWriter) Write(b []byte) (int, error) {
+	n, err := w.dst.Write(b)
+	if n > 0 {
+		w.act.touch()
+	}
+	if err == nil {
+		tcpserver.Idle(w.src)
+	}
+	return n, err
+}
+
+//closeIdle 两个方向都没有转发数据超过timeout时关闭两端的连接，让io.Copy返回，stop关闭时退出
+//下载这类只有上游向客户端发送数据的连接，数据一直在转发就不会被关闭
+func closeIdle(stop <-chan struct{}, act *activity, timeout time.Duration, client, server net.Conn) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		if idle := act.idle(); idle < timeout {
+			timer.Reset(timeout - idle)
+			continue
+		}
+		fmt.Printf("%s idle timeout, closed\n", client.RemoteAddr())
+		client.Close()
+		server.Close()
+		return
+	}
+}
+
+//handle 转发一个客户端连接
+//一个方向的数据复制完（读到EOF）后只关闭另一端的写端，另一个方向的数据可以继续复制，
+//出错时关闭两端的连接，让另一个方向的io.Copy也返回
+func (p *proxy) handle(ctx context.Context, client net.Conn) {
+	u, server, err := p.dial()
+	if err != nil {
+		fmt.Printf("%s: %v\n", client.RemoteAddr(), err)
+		return
+	}
+	defer server.Close()
+	start := time.Now()
+	act := &activity{}
+	act.touch()
+	if *idleTimeout > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go closeIdle(stop, act, *idleTimeout, client, server)
+	}
+
+	var sent, received int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var err error
+		sent, err = io.Copy(forwardWriter{dst: server, src: client, act: act}, client)
+		//服务端关闭时读取客户端会返回超时错误，这时只关闭上游的写端，让已经发出去的请求的回复继续返回
+		if err != nil && ctx.Err() == nil {
+			client.Close()
+			server.Close()
+			return
+		}
+		closeWrite(server)
+	}()
+	received, err = io.Copy(forwardWriter{dst: client, src: server, act: act}, server)
+	if err != nil {
+		client.Close()
+		server.Close()
+	} else {
+		closeWrite(client)
+	}
+	wg.Wait()
+	fmt.Printf("%s <-> %s closed, sent:%d bytes received:%d bytes duration:%v\n",
+		client.RemoteAddr(), u.addr, sent, received, time.Since(start).Round(time.Millisecond))
+}
+
+func main() {
+	flag.Parse()
+	addrs := make([]string, 0)
+	for _, addr := range strings.Split(*upstreams, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		fmt.Println("at least one upstream is required")
+		return
+	}
+	p := newProxy(addrs, *healthInterval > 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *healthInterval > 0 {
+		go p.healthCheck(ctx, *healthInterval)
+	}
+
+	//tcpserver的IdleTimeout只统计读取客户端的数据，代理自己按两个方向的数据统计空闲时间
+	srv := &tcpserver.Server{
+		Addr:    *listen,
+		Handler: p.handle,
+	}
+	stopped := make(chan struct{})
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigChan
+		fmt.Printf("received %v, shutting down...\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Printf("shutdown failed, err:%v\n", err)
+		}
+		close(stopped)
+	}()
+
+	fmt.Printf("proxy listening on %s, upstreams:%v\n", *listen, addrs)
+	if err := srv.Serve(ctx); err != tcpserver.ErrServerClosed {
+		fmt.Printf("serve failed, err:%v\n", err)
+		return
+	}
+	<-stopped
+	fmt.Println("proxy stopped")
+}
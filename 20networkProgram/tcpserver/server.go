@@ -0,0 +1,273 @@
+package tcpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/tlsutil"
+)
+
+//可以优雅关闭的TCP服务端：
+//Serve负责监听和接受连接，每个连接启动一个goroutine调用Handler处理
+//Shutdown停止接受新连接，等待正在处理的连接结束，超过ctx的期限后强制关闭所有连接
+
+//TLS握手的超时时间
+const handshakeTimeout = 10 * time.Second
+
+//ErrServerClosed Serve在调用Shutdown或者ctx取消后返回的错误
+var ErrServerClosed = errors.New("tcpserver: server closed")
+
+//Handler 处理一个连接，ctx在服务端关闭时会被取消，Handler返回后连接会被关闭
+//Handler每处理完一条消息应该调用Idle，服务端关闭时只打断两条消息之间的读取
+type Handler func(ctx context.Context, conn net.Conn)
+
+//Server TCP服务端
+type Server struct {
+	Addr        string        //监听的地址，如127.0.0.1:2000
+	Handler     Handler       //连接的处理函数
+	MaxConns    int           //最大连接数，超过时新连接直接关闭，0表示不限制
+	IdleTimeout time.Duration //每次读取的超时时间，连接空闲超过该时间读取会返回超时错误，0表示不限制
+	TLSConfig   *tls.Config   //不为nil时使用TLS
+
+	mu         sync.Mutex
+	listener   net.Listener
+	conns      map[*conn]struct{}
+	inShutdown bool
+	cancel     context.CancelFunc //取消所有Handler的ctx
+	wg         sync.WaitGroup     //正在处理的连接
+}
+
+//conn 包装net.Conn，每次读取前设置读超时，并记录连接是否处于两条消息之间
+//这些状态只和这个连接有关，用连接自己的锁保护，读写时不需要获取Server的锁
+type conn struct {
+	net.Conn
+	srv *Server
+
+	mu      sync.Mutex
+	idle    bool //还没有读到下一条消息：刚建立连接，或者Handler调用Idle表示上一条消息已经处理完
+	closing bool //服务端正在关闭，不再读取新消息
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.closing && c.idle {
+		//关闭过程中不再读取新消息，立即返回超时错误
+		c.Conn.SetReadDeadline(time.Now())
+	} else if c.srv.IdleTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.srv.IdleTimeout))
+	}
+	c.mu.Unlock()
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mu.Lock()
+		if c.closing && c.idle {
+			//Shutdown时正好读到了新消息，撤销Shutdown设置的期限，让这条消息读完并处理完
+			c.resetReadDeadline()
+		}
+		c.idle = false
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+//Idle 标记conn回到了两条消息之间，Handler处理完一条消息、准备读取下一条消息时调用
+//用bufio.Reader读取时，只有缓冲区中没有下一条消息的数据（Buffered()==0）时才能调用
+//服务端正在关闭时，下一次读取会立即返回超时错误；conn不是Server接受的连接时什么都不做
+func Idle(nc net.Conn) {
+	c, ok := nc.(*conn)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idle = true
+	if c.closing {
+		c.Conn.SetReadDeadline(time.Now())
+	}
+}
+
+//shutdown 标记连接正在关闭，阻塞在读取下一条消息上的连接立即返回，正在处理消息的连接等回复后再返回
+func (c *conn) shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closing = true
+	if c.idle {
+		c.Conn.SetReadDeadline(time.Now())
+	}
+}
+
+//resetReadDeadline 恢复正常的读超时，调用方需持有c.mu
+func (c *conn) resetReadDeadline() {
+	if c.srv.IdleTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.srv.IdleTimeout))
+	} else {
+		c.Conn.SetReadDeadline(time.Time{})
+	}
+}
+
+//CloseWrite 关闭写端，对端读取时会收到EOF，底层连接（*net.TCPConn、*tls.Conn）不支持时返回错误
+func (c *conn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("tcpserver: CloseWrite not supported")
+}
+
+//Serve 监听Addr并处理连接，直到调用Shutdown或者ctx被取消
+//ctx同时也是所有Handler的ctx的父context
+func (s *Server) Serve(ctx context.Context) error {
+	if s.Handler == nil {
+		return errors.New("tcpserver: nil handler")
+	}
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if s.TLSConfig != nil {
+		listener = tls.NewListener(listener, s.TLSConfig)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	if s.inShutdown {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.cancel = cancel
+	if s.conns == nil {
+		s.conns = make(map[*conn]struct{})
+	}
+	s.mu.Unlock()
+
+	//ctx取消时关闭监听，让Accept返回
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || s.shuttingDown() {
+				return ErrServerClosed
+			}
+			//临时错误时稍等一下再继续接受连接，参照net/http中的处理
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				fmt.Printf("accept failed, err:%v\n", err)
+				time.Sleep(50 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		tc := &conn{Conn: c, srv: s, idle: true}
+		if err := s.track(tc); err != nil {
+			fmt.Printf("reject %s, err:%v\n", c.RemoteAddr(), err)
+			c.Close()
+			continue
+		}
+		go s.handle(ctx, tc)
+	}
+}
+
+//track 记录新连接，超过最大连接数或者正在关闭时返回错误
+func (s *Server) track(c *conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inShutdown {
+		return ErrServerClosed
+	}
+	if s.MaxConns > 0 && len(s.conns) >= s.MaxConns {
+		return fmt.Errorf("too many connections, max:%d", s.MaxConns)
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return nil
+}
+
+func (s *Server) handle(ctx context.Context, c *conn) {
+	defer func() {
+		c.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+	//TLS握手默认在第一次读写时才进行，这里提前握手，失败时打印明确的错误，不再交给Handler处理
+	if tc, ok := c.Conn.(*tls.Conn); ok {
+		tc.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err := tc.Handshake(); err != nil {
+			fmt.Printf("tls handshake with %s failed, err:%s\n", c.RemoteAddr(), tlsutil.Explain(err))
+			return
+		}
+		//握手期间Shutdown可能已经设置了读的期限，这时不能清掉，之后的读取由Read处理
+		tc.SetWriteDeadline(time.Time{})
+		c.mu.Lock()
+		if !c.closing {
+			tc.SetReadDeadline(time.Time{})
+		}
+		c.mu.Unlock()
+	}
+	s.Handler(ctx, c)
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inShutdown
+}
+
+//NumConns 当前的连接数
+func (s *Server) NumConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+//Shutdown 优雅关闭：停止接受新连接，取消Handler的ctx，
+//阻塞在读取下一条消息上的连接立即返回超时错误，正在读取或处理消息的连接等Handler调用Idle后，下一次读取再返回超时错误，
+//所有连接结束后返回nil，ctx先结束时强制关闭剩下的连接并返回ctx.Err()
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.inShutdown = true
+	listener, cancel := s.listener, s.cancel
+	s.mu.Unlock()
+
+	if listener != nil {
+		listener.Close()
+	}
+	//先取消Handler的ctx，Handler读取返回超时错误时可以通过ctx.Err()知道是服务端在关闭
+	if cancel != nil {
+		cancel()
+	}
+	s.mu.Lock()
+	for c := range s.conns {
+		c.shutdown()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,130 @@
+package tcpserver
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Moqqll/02goLearning/20networkProgram/proto"
+)
+
+//startServer 在随机端口上启动s，返回监听的地址
+func startServer(t *testing.T, s *Server) string {
+	t.Helper()
+	s.Addr = "127.0.0.1:0"
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(context.Background()) }()
+	t.Cleanup(func() {
+		s.Shutdown(context.Background())
+		if err := <-served; err != ErrServerClosed {
+			t.Errorf("Serve err = %v, want ErrServerClosed", err)
+		}
+	})
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		l := s.listener
+		s.mu.Unlock()
+		if l != nil {
+			return l.Addr().String()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server did not start")
+	return ""
+}
+
+//echoHandler 每读到一条消息就原样回复，读取失败时把错误和当时的ctx.Err()发给errs
+func echoHandler(written chan<- struct{}, errs chan<- [2]error) Handler {
+	return func(ctx context.Context, conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		//像聊天室中的hub一样，在读取消息的同时写连接
+		b, _ := proto.Encode("welcome")
+		conn.Write(b)
+		close(written)
+		for {
+			if reader.Buffered() == 0 {
+				Idle(conn)
+			}
+			msg, err := proto.Decode(reader)
+			if err != nil {
+				errs <- [2]error{err, ctx.Err()}
+				return
+			}
+			b, _ := proto.Encode(msg)
+			conn.Write(b)
+		}
+	}
+}
+
+func TestShutdownIdleConn(t *testing.T) {
+	written := make(chan struct{})
+	errs := make(chan [2]error, 1)
+	s := &Server{Handler: echoHandler(written, errs)}
+	addr := startServer(t, s)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed, err:%v", err)
+	}
+	defer conn.Close()
+	<-written
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown err = %v, want nil", err)
+	}
+	//读取被打断时ctx已经取消，Handler可以知道是服务端在关闭
+	got := <-errs
+	if ne, ok := got[0].(net.Error); !ok || !ne.Timeout() {
+		t.Fatalf("read err = %v, want timeout", got[0])
+	}
+	if got[1] == nil {
+		t.Fatalf("ctx.Err() = nil when read was interrupted by Shutdown")
+	}
+}
+
+func TestShutdownInFlightMessage(t *testing.T) {
+	written := make(chan struct{})
+	errs := make(chan [2]error, 1)
+	s := &Server{Handler: echoHandler(written, errs)}
+	addr := startServer(t, s)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed, err:%v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if msg, err := proto.Decode(reader); err != nil || msg != "welcome" {
+		t.Fatalf("Decode = %q, %v, want welcome", msg, err)
+	}
+
+	//先发送半条消息，服务端写过连接也不能认为连接处于两条消息之间
+	pkg, _ := proto.Encode("hello")
+	if _, err := conn.Write(pkg[:3]); err != nil {
+		t.Fatalf("write failed, err:%v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		shutdown <- s.Shutdown(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := conn.Write(pkg[3:]); err != nil {
+		t.Fatalf("write failed, err:%v", err)
+	}
+
+	//正在读取的消息要读完并回复，之后连接才被关闭
+	if msg, err := proto.Decode(reader); err != nil || msg != "hello" {
+		t.Fatalf("Decode = %q, %v, want hello", msg, err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown err = %v, want nil", err)
+	}
+	if got := <-errs; got[1] == nil {
+		t.Fatalf("read err = %v with ctx.Err() = nil, want shutdown", got[0])
+	}
+}
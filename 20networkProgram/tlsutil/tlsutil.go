@@ -0,0 +1,112 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+//加载gencert生成的证书，构造服务端和客户端的tls.Config，并给常见的握手错误加上排查提示
+
+//loadCertPool 从PEM文件中加载信任的CA证书
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+	return pool, nil
+}
+
+//ServerConfig 服务端的配置，clientCAFile不为空时开启双向认证（mTLS），要求客户端提供由该CA签发的证书
+func ServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate failed, err:%v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client ca failed, err:%v", err)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+//ClientConfig 客户端的配置，caFile为空时使用系统的根证书，
+//certFile和keyFile不为空时向服务端提供客户端证书，serverName不为空时用它校验服务端证书中的域名
+func ClientConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("load ca failed, err:%v", err)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate failed, err:%v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+//Explain 在握手错误后面加上可能的原因
+func Explain(err error) string {
+	if hint := hintOf(err); hint != "" {
+		return fmt.Sprintf("%v (%s)", err, hint)
+	}
+	return err.Error()
+}
+
+//IsCertError 是否是证书校验失败，这类错误重试也不会成功
+func IsCertError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	return errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalid) ||
+		strings.Contains(err.Error(), "bad certificate") ||
+		strings.Contains(err.Error(), "certificate required")
+}
+
+func hintOf(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	msg := err.Error()
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "对端的证书不是由信任的CA签发的，检查--ca或--client-ca是否是gencert生成的ca.pem"
+	case errors.As(err, &hostname):
+		return "证书中没有连接使用的地址，用--server-name指定证书中的域名，或者用gencert --hosts重新生成证书"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "证书已过期或者还没有生效，检查系统时间或者重新生成证书"
+	case errors.As(err, &invalid) && invalid.Reason == x509.IncompatibleUsage:
+		return "证书的用途不对，服务端和客户端要分别使用server.pem和client.pem"
+	case strings.Contains(msg, "bad certificate"), strings.Contains(msg, "certificate required"):
+		return "对端拒绝了本端的证书，检查对端信任的CA，开启双向认证时客户端要用--cert和--key提供由服务端信任的CA签发的证书"
+	case strings.Contains(msg, "didn't provide a certificate"):
+		return "服务端开启了双向认证，客户端需要用--cert和--key提供证书"
+	case strings.Contains(msg, "first record does not look like a TLS handshake"):
+		return "对端没有使用TLS"
+	}
+	return ""
+}
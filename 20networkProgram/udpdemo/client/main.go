@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// UDP client端
+//echo模式：从标准输入按行读取消息发送给服务端，打印服务端发回的数据和往返时间，输入quit退出
+//measure模式：按固定间隔发送带序号和时间戳的数据包，统计丢包率、乱序和往返时间
+
+var (
+	addr     = flag.String("addr", "127.0.0.1:3000", "服务端地址")
+	mode     = flag.String("mode", "echo", "运行模式：echo或measure")
+	timeout  = flag.Duration("timeout", time.Second, "echo模式下等待回复的超时时间，measure模式下发送完后等待剩余回复的时间")
+	count    = flag.Int("count", 100, "measure模式下发送的数据包个数")
+	size     = flag.Int("size", 64, "measure模式下每个数据包的字节数")
+	interval = flag.Duration("interval", 10*time.Millisecond, "measure模式下发送数据包的间隔")
+)
+
+const (
+	maxPacketSize = 65507 //UDP数据包的最大长度
+	headerLen     = 12    //measure模式的包头：4字节序号+8字节发送时间
+	echoHeaderLen = 4     //echo模式的包头：4字节序号
+)
+
+//usageError 打印参数错误和用法后退出
+func usageError(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	flag.Usage()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Parse()
+	if *mode == "measure" {
+		if *size < headerLen || *size > maxPacketSize {
+			usageError("size must be between %d and %d", headerLen, maxPacketSize)
+		}
+		if *count <= 0 {
+			usageError("count must be greater than 0")
+		}
+		if *interval <= 0 {
+			usageError("interval must be greater than 0")
+		}
+	}
+	//UDP的Dial不会发送任何数据，只是让socket只和服务端通信，之后可以直接用Read和Write
+	socket, err := net.Dial("udp", *addr)
+	if err != nil {
+		fmt.Printf("dial failed, err:%v\n", err)
+		return
+	}
+	defer socket.Close()
+	switch *mode {
+	case "echo":
+		echo(socket.(*net.UDPConn))
+	case "measure":
+		measure(socket.(*net.UDPConn)).print()
+	default:
+		fmt.Printf("unknown mode:%s\n", *mode)
+	}
+}
+
+//echo 发送标准输入中的每一行，并等待服务端发回
+//每个数据包的前4个字节是序号，超时之后才到的回复序号对不上，直接丢弃，不会被当作下一行的回复
+func echo(socket *net.UDPConn) {
+	reader := bufio.NewReader(os.Stdin)
+	data := make([]byte, maxPacketSize)
+	var seq uint32
+	for {
+		text, err := reader.ReadString('\n')
+		text = strings.TrimRight(text, "\r\n")
+		if text == "quit" {
+			return
+		}
+		if text != "" {
+			seq++
+			packet := make([]byte, echoHeaderLen+len(text))
+			binary.BigEndian.PutUint32(packet[0:echoHeaderLen], seq)
+			copy(packet[echoHeaderLen:], text)
+			start := time.Now()
+			if _, err := socket.Write(packet); err != nil {
+				fmt.Printf("send data failed, err:%v\n", err)
+				return
+			}
+			//UDP不保证送达，必须设置超时，否则数据包丢失时会一直阻塞
+			socket.SetReadDeadline(start.Add(*timeout))
+			for {
+				n, err := socket.Read(data)
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					fmt.Printf("no reply within %v, packet lost\n", *timeout)
+				} else if err != nil {
+					fmt.Printf("recv data failed, err:%v\n", err)
+				} else if n < echoHeaderLen || binary.BigEndian.Uint32(data[0:echoHeaderLen]) != seq {
+					//之前超时的数据包的回复，继续等待这一行的回复
+					continue
+				} else {
+					fmt.Printf("< %s (%d bytes, rtt %v)\n", data[echoHeaderLen:n], n-echoHeaderLen, time.Since(start))
+				}
+				break
+			}
+		}
+		if err != nil { //标准输入关闭
+			return
+		}
+	}
+}
+
+//report measure模式的统计结果
+type report struct {
+	sent       int
+	received   int
+	duplicates int //同一个序号收到多次
+	reordered  int //收到的序号比之前收到的最大序号小
+	sendErrors int
+	recvErrors int
+	rtts       []time.Duration
+	elapsed    time.Duration
+}
+
+//measure 按固定间隔发送count个数据包，同时接收服务端发回的数据包
+//每个数据包的前4个字节是序号，后8个字节是相对于开始时间的发送时间，
+//服务端原样发回，所以客户端收到后就能算出往返时间，也能根据序号判断丢包和乱序
+func measure(socket *net.UDPConn) *report {
+	r := &report{rtts: make([]time.Duration, 0, *count)}
+	start := time.Now()
+	sendDone := make(chan struct{})
+	//sendErrors只在发送的goroutine中修改，sendDone关闭后才会读取
+	go func() {
+		defer close(sendDone)
+		packet := make([]byte, *size)
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for seq := 0; seq < *count; seq++ {
+			binary.BigEndian.PutUint32(packet[0:4], uint32(seq))
+			binary.BigEndian.PutUint64(packet[4:12], uint64(time.Since(start)))
+			if _, err := socket.Write(packet); err != nil {
+				r.sendErrors++
+			}
+			<-ticker.C
+		}
+	}()
+
+	received := make([]bool, *count)
+	maxSeq := -1
+	data := make([]byte, maxPacketSize)
+	for {
+		//发送过程中一直等待，发送完后最多再等待timeout
+		if sendDone != nil {
+			select {
+			case <-sendDone:
+				sendDone = nil
+				socket.SetReadDeadline(time.Now().Add(*timeout))
+			default:
+				socket.SetReadDeadline(time.Now().Add(*interval + *timeout))
+			}
+		}
+		n, err := socket.Read(data)
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			if sendDone == nil {
+				break
+			}
+			continue
+		}
+		if err != nil { //比如服务端没有启动时会收到connection refused
+			r.recvErrors++
+			continue
+		}
+		now := time.Since(start)
+		if n < headerLen {
+			r.recvErrors++
+			continue
+		}
+		seq := int(binary.BigEndian.Uint32(data[0:4]))
+		sentAt := time.Duration(binary.BigEndian.Uint64(data[4:12]))
+		if seq >= *count {
+			r.recvErrors++
+			continue
+		}
+		if received[seq] {
+			r.duplicates++
+			continue
+		}
+		received[seq] = true
+		r.received++
+		if seq < maxSeq {
+			r.reordered++
+		} else {
+			maxSeq = seq
+		}
+		r.rtts = append(r.rtts, now-sentAt)
+	}
+	r.sent = *count - r.sendErrors
+	r.elapsed = time.Since(start)
+	return r
+}
+
+//print 打印统计结果
+func (r *report) print() {
+	fmt.Printf("sent:%d received:%d lost:%d (%.2f%%) duplicates:%d reordered:%d elapsed:%v\n",
+		r.sent, r.received, r.sent-r.received, r.lossRate()*100, r.duplicates, r.reordered, r.elapsed)
+	if r.sendErrors > 0 || r.recvErrors > 0 {
+		fmt.Printf("send errors:%d recv errors:%d\n", r.sendErrors, r.recvErrors)
+	}
+	if len(r.rtts) == 0 {
+		return
+	}
+	min, max, sum := r.rtts[0], r.rtts[0], time.Duration(0)
+	for _, d := range r.rtts {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		sum += d
+	}
+	fmt.Printf("rtt min:%v avg:%v max:%v\n", min, sum/time.Duration(len(r.rtts)), max)
+}
+
+//lossRate 丢包率
+func (r *report) lossRate() float64 {
+	if r.sent == 0 {
+		return 0
+	}
+	return float64(r.sent-r.received) / float64(r.sent)
+}
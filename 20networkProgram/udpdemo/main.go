@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// UDP server端
+//把收到的每个数据包原样发回给对端（echo），并按对端地址统计收到的包数和字节数
+
+var (
+	addr          = flag.String("addr", "127.0.0.1:3000", "监听的地址")
+	statsInterval = flag.Duration("stats-interval", 10*time.Second, "打印统计信息的间隔，0表示只在退出时打印")
+)
+
+//UDP数据包的最大长度：65535-8字节的UDP头-20字节的IP头
+const maxPacketSize = 65507
+
+//peerStats 一个对端的统计信息
+type peerStats struct {
+	packets int
+	bytes   int
+	first   time.Time
+	last    time.Time
+}
+
+//stats 所有对端的统计信息，读取数据和打印统计在不同的goroutine中，需要加锁
+type stats struct {
+	mu    sync.Mutex
+	peers map[string]*peerStats
+}
+
+func (s *stats) record(peer string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.peers[peer]
+	if !ok {
+		ps = &peerStats{first: time.Now()}
+		s.peers[peer] = ps
+	}
+	ps.packets++
+	ps.bytes += n
+	ps.last = time.Now()
+}
+
+//print 按收到的包数从多到少打印统计信息
+func (s *stats) print() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.peers) == 0 {
+		fmt.Println("还没有收到数据")
+		return
+	}
+	peers := make([]string, 0, len(s.peers))
+	for k := range s.peers {
+		peers = append(peers, k)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return s.peers[peers[i]].packets > s.peers[peers[j]].packets
+	})
+	fmt.Printf("%-22s %10s %12s %-12s %s\n", "peer", "packets", "bytes", "first seen", "last seen")
+	for _, k := range peers {
+		ps := s.peers[k]
+		fmt.Printf("%-22s %10d %12d %-12s %s\n", k, ps.packets, ps.bytes,
+			ps.first.Format("15:04:05.000"), ps.last.Format("15:04:05.000"))
+	}
+}
+
+func main() {
+	flag.Parse()
+	udpAddr, err := net.ResolveUDPAddr("udp", *addr)
+	if err != nil {
+		fmt.Printf("resolve addr failed, err:%v\n", err)
+		return
+	}
+	//UDP不需要建立连接，监听后直接收发数据
+	listen, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		fmt.Printf("listen failed, err:%v\n", err)
+		return
+	}
+	defer listen.Close()
+	fmt.Printf("listening on %s\n", *addr)
+
+	st := &stats{peers: make(map[string]*peerStats)}
+	if *statsInterval > 0 {
+		go func() {
+			for range time.Tick(*statsInterval) {
+				st.print()
+			}
+		}()
+	}
+	//收到SIGINT或SIGTERM时关闭监听，ReadFromUDP会返回错误
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		listen.Close()
+	}()
+
+	//UDP每次读取一个完整的数据包，不存在黏包问题，但缓冲区比数据包小时多出的部分会被丢弃
+	data := make([]byte, maxPacketSize)
+	for {
+		n, peer, err := listen.ReadFromUDP(data) //接收数据
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				fmt.Printf("read from udp failed, err:%v\n", err)
+				continue
+			}
+			break //监听已关闭
+		}
+		st.record(peer.String(), n)
+		if _, err := listen.WriteToUDP(data[:n], peer); err != nil { //原样发回
+			fmt.Printf("write to %v failed, err:%v\n", peer, err)
+		}
+	}
+	st.print()
+	fmt.Println("server stopped")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/router"
 )
 
 func a(w http.ResponseWriter, r *http.Request) {
@@ -15,6 +17,9 @@ func a(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	http.HandleFunc("/", a)
-	http.ListenAndServe("127.0.0.1:9000", nil)
+	r := router.New()
+	r.GET("/", a)
+	//通配符匹配/files/后面的整个路径，交给http.FileServer返回当前目录下的文件
+	r.Handle(http.MethodGet, "/files/*filepath", http.StripPrefix("/files", http.FileServer(http.Dir("."))))
+	http.ListenAndServe("127.0.0.1:9000", r)
 }
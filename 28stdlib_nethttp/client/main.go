@@ -1,13 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
-	"strings"
+	"time"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/httpclient"
 )
 
+//client 所有请求共用一个客户端，复用连接，失败时自动重试
+var client = httpclient.New()
+
+func init() {
+	client.Timeout = 3 * time.Second
+	//钩子：打印每次尝试的结果
+	client.Hooks = append(client.Hooks, func(a *httpclient.Attempt) {
+		status := "-"
+		if a.Response != nil {
+			status = a.Response.Status
+		}
+		fmt.Printf("[%s %s] attempt:%d status:%s err:%v duration:%v retry:%v\n",
+			a.Request.Method, a.Request.URL.Path, a.Attempt, status, a.Err, a.Duration.Round(time.Millisecond), a.WillRetry)
+	})
+}
+
 func myGet() {
 	apiURL := "http://127.0.0.1:9000/get"
 	//URL param
@@ -22,9 +40,11 @@ func myGet() {
 	//URL 编码
 	u.RawQuery = data.Encode()
 	fmt.Println(u.String())
-	resp, err := http.Get(u.String())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.Get(ctx, u.String())
 	if err != nil {
-		fmt.Printf("post failed, err:%v\n", err)
+		fmt.Printf("get failed, err:%v\n", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -37,14 +57,13 @@ func myGet() {
 }
 
 func myPost() {
-	url := "http://127.0.0.1:9000/post"
 	//表单数据
-	contentType := "application/x-www-form-urlencoded"
-	data := "name=小王子&age=18"
-	//json
-	// contentType := "application/json"
-	// data := `{"name":"小王子","age":18}`
-	resp, err := http.Post(url, contentType, strings.NewReader(data))
+	data := url.Values{}
+	data.Set("name", "小王子")
+	data.Set("age", "18")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.PostForm(ctx, "http://127.0.0.1:9000/post", data)
 	if err != nil {
 		fmt.Printf("post failed ,err:%v\n", err)
 		return
@@ -58,7 +77,27 @@ func myPost() {
 	fmt.Println(string(b))
 }
 
+//myPostJSON 以json格式发送请求，回复直接解码到结构体中
+func myPostJSON() {
+	in := map[string]interface{}{"name": "小王子", "age": 18}
+	var out struct {
+		Status string `json:"status"`
+		User   struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		} `json:"user"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.PostJSON(ctx, "http://127.0.0.1:9000/post", in, &out); err != nil {
+		fmt.Printf("post json failed, err:%v\n", err)
+		return
+	}
+	fmt.Printf("%+v\n", out)
+}
+
 func main() {
 	myGet()
 	myPost()
+	myPostJSON()
 }
@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//ErrCircuitOpen 熔断器打开时直接返回的错误，不会真的发出请求
+var ErrCircuitOpen = errors.New("httpclient: circuit breaker is open")
+
+//State 熔断器的状态
+type State int
+
+//熔断器的三种状态：
+//  Closed    正常发送请求，连续失败FailureThreshold次后打开
+//  Open      直接返回ErrCircuitOpen，OpenTimeout后进入HalfOpen
+//  HalfOpen  只放行一个试探请求，成功后关闭，失败后重新打开
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+//breaker 一个host的熔断器
+type breaker struct {
+	host      string
+	threshold int
+	timeout   time.Duration
+	onChange  func(host string, from, to State)
+
+	mu         sync.Mutex
+	state      State
+	generation uint64    //每次状态变化加一，用来识别状态变化之前发出的请求
+	failures   int       //连续失败的次数
+	openedAt   time.Time //打开的时间
+	probing    bool      //HalfOpen状态下是否已经有试探请求了
+}
+
+//permit allow放行请求时发的凭证，记录请求是在哪一代状态下发出的
+type permit struct {
+	generation uint64
+	probe      bool //HalfOpen状态下的试探请求
+}
+
+//allow 是否可以发送请求，可以时返回的凭证要交给record或release
+func (b *breaker) allow() (permit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.timeout {
+			return permit{}, fmt.Errorf("%w: %s", ErrCircuitOpen, b.host)
+		}
+		b.setState(HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if b.probing {
+			return permit{}, fmt.Errorf("%w: %s", ErrCircuitOpen, b.host)
+		}
+		b.probing = true
+		return permit{generation: b.generation, probe: true}, nil
+	}
+	return permit{generation: b.generation}, nil
+}
+
+//record 记录一次请求的结果
+//HalfOpen状态只看试探请求的结果；状态变化之前发出的请求结果已经过时（比如打开之前发出、打开之后才成功的请求），直接忽略
+func (b *breaker) record(p permit, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p.probe {
+		b.probing = false
+		b.failures = 0
+		if failed {
+			b.open()
+		} else {
+			b.setState(Closed)
+		}
+		return
+	}
+	if p.generation != b.generation {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+//release 请求被调用方取消时，既不算成功也不算失败，试探请求释放试探的名额
+func (b *breaker) release(p permit) {
+	if !p.probe {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+//open 打开熔断器，调用方需持有b.mu
+func (b *breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(Open)
+}
+
+func (b *breaker) setState(s State) {
+	from := b.state
+	if from != s {
+		b.generation++
+	}
+	b.state = s
+	if b.onChange != nil && from != s {
+		b.onChange(b.host, from, s)
+	}
+}
@@ -0,0 +1,264 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Attempt 一次请求尝试的结果，每次尝试结束后传给所有的钩子，可以用来记录日志和统计
+type Attempt struct {
+	Request   *http.Request
+	Attempt   int //第几次尝试，从1开始
+	Response  *http.Response
+	Err       error
+	Duration  time.Duration
+	WillRetry bool //是否还会重试
+}
+
+//Hook 钩子，在钩子中不要读取Response.Body
+type Hook func(a *Attempt)
+
+//Client 可以共用的HTTP客户端，多个goroutine可以同时使用
+//底层的http.Client复用连接，每次尝试的超时用context控制，幂等的请求失败时按指数退避重试，每个host一个熔断器
+type Client struct {
+	HTTPClient *http.Client //底层的客户端，默认使用http.DefaultTransport，复用连接
+
+	Timeout    time.Duration //每次尝试的超时时间，0表示只受调用方ctx的限制
+	MaxRetries int           //最多重试的次数，只重试幂等的请求
+	MinBackoff time.Duration //第一次重试前等待的最长时间，之后每次翻倍
+	MaxBackoff time.Duration //重试前等待的最长时间
+
+	FailureThreshold int           //连续失败多少次后打开熔断器，0表示不熔断
+	OpenTimeout      time.Duration //熔断器打开多久后放行一个试探请求
+	//OnStateChange 熔断器状态变化时调用
+	OnStateChange func(host string, from, to State)
+
+	Hooks []Hook
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+//New ...构造函数，使用默认的配置，可以在第一次发送请求前修改字段
+func New() *Client {
+	return &Client{
+		HTTPClient:       &http.Client{},
+		Timeout:          10 * time.Second,
+		MaxRetries:       2,
+		MinBackoff:       100 * time.Millisecond,
+		MaxBackoff:       2 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+	}
+}
+
+//breaker 取出host的熔断器，没有时创建
+func (c *Client) breaker(host string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.breakers == nil {
+		c.breakers = make(map[string]*breaker)
+	}
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &breaker{host: host, threshold: c.FailureThreshold, timeout: c.OpenTimeout, onChange: c.OnStateChange}
+		c.breakers[host] = b
+	}
+	return b
+}
+
+//idempotent 重复发送不会有副作用的请求才能重试，POST请求带了Idempotency-Key时也可以重试
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+//retryable 网络错误、每次尝试的超时以及429和502/503/504可以重试
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+//backoff 第attempt次失败后等待的时间：[0, MinBackoff*2^(attempt-1))中的随机值（full jitter），不超过MaxBackoff
+//服务端回复了Retry-After时按它等待，同样不超过MaxBackoff
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+			d := time.Duration(s) * time.Second
+			if d > c.MaxBackoff {
+				d = c.MaxBackoff
+			}
+			return d
+		}
+	}
+	d := c.MinBackoff << uint(attempt-1)
+	if d > c.MaxBackoff || d <= 0 {
+		d = c.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+//cancelBody 回复体关闭时才取消这次尝试的ctx，否则调用方还没读完回复体连接就被关闭了
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (cb *cancelBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.cancel()
+	return err
+}
+
+//discard 丢弃不再需要的回复，读完一小部分回复体后关闭，小的回复体读完后连接可以复用
+func discard(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+}
+
+//try 发送一次请求，每次尝试都使用新的ctx和新的请求体
+func (c *Client) try(req *http.Request, attempt int) (*http.Response, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	r := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		r.Body = body
+	}
+	resp, err := c.HTTPClient.Do(r)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return resp, cancel, nil
+}
+
+//Do 发送请求，按配置重试和熔断，返回的回复体必须关闭
+//只有幂等的请求、并且请求体可以重新读取（http.NewRequest使用bytes.Reader、strings.Reader等时会设置GetBody）时才会重试
+//重试之前熔断器被这次调用自己的失败打开时，返回上一次尝试的结果，而不是ErrCircuitOpen
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	canRetry := c.MaxRetries > 0 && idempotent(req) &&
+		(req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	var b *breaker
+	if c.FailureThreshold > 0 {
+		b = c.breaker(req.URL.Host)
+	}
+	//上一次尝试的结果，确定可以重试之后才丢弃
+	var lastResp *http.Response
+	var lastCancel context.CancelFunc
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		var p permit
+		if b != nil {
+			var err error
+			if p, err = b.allow(); err != nil {
+				c.runHooks(&Attempt{Request: req, Attempt: attempt, Err: err})
+				if attempt > 1 {
+					return result(lastResp, lastCancel, lastErr)
+				}
+				return nil, err
+			}
+		}
+		if lastResp != nil {
+			discard(lastResp)
+			lastCancel()
+			lastResp = nil
+		}
+		resp, cancel, err := c.try(req, attempt)
+		if b != nil {
+			if ctx.Err() != nil { //调用方取消了，不是服务端的问题
+				b.release(p)
+			} else {
+				b.record(p, err != nil || resp.StatusCode >= 500)
+			}
+		}
+		willRetry := canRetry && attempt <= c.MaxRetries && ctx.Err() == nil && retryable(resp, err)
+		c.runHooks(&Attempt{
+			Request:   req,
+			Attempt:   attempt,
+			Response:  resp,
+			Err:       err,
+			Duration:  time.Since(start),
+			WillRetry: willRetry,
+		})
+		if !willRetry {
+			return result(resp, cancel, err)
+		}
+
+		lastResp, lastCancel, lastErr = resp, cancel, err
+		select {
+		case <-time.After(c.backoff(attempt, resp)):
+		case <-ctx.Done():
+			if lastResp != nil {
+				discard(lastResp)
+				lastCancel()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+//result 把一次尝试的结果返回给调用方，回复体关闭时才取消这次尝试的ctx
+func result(resp *http.Response, cancel context.CancelFunc, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (c *Client) runHooks(a *Attempt) {
+	for _, h := range c.Hooks {
+		h(a)
+	}
+}
+
+//Get 发送GET请求
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req.WithContext(ctx))
+}
+
+//PostForm 以application/x-www-form-urlencoded格式发送表单，POST请求不会重试
+func (c *Client) PostForm(ctx context.Context, url string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(req.WithContext(ctx))
+}
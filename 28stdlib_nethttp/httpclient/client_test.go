@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//熔断器被这次调用自己的失败打开后，返回最后一次尝试的结果
+func TestDoReturnsLastAttemptWhenBreakerOpens(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("try later"))
+	}))
+	defer srv.Close()
+	c := New()
+	c.MaxRetries = 3
+	c.MinBackoff = time.Millisecond
+	c.FailureThreshold = 2
+	c.OpenTimeout = time.Minute
+
+	resp, err := c.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Get err = %v, want the last 503 response", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("StatusCode = %d, want 503", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("server got %d requests, want 2", n)
+	}
+
+	//之后的调用第一次尝试就被熔断器拒绝
+	if _, err := c.Get(context.Background(), srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get err = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestDoReturnsLastErrorWhenBreakerOpens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close() //连接会被拒绝
+	c := New()
+	c.MaxRetries = 3
+	c.MinBackoff = time.Millisecond
+	c.FailureThreshold = 1
+	c.OpenTimeout = time.Minute
+
+	_, err := c.Get(context.Background(), url)
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get err = %v, want the connection error", err)
+	}
+}
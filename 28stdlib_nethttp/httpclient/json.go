@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+//StatusError 服务端回复了非2xx的状态码，Body是回复体的前64KB
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpclient: unexpected status %s: %.200s", e.Status, bytes.TrimSpace(e.Body))
+}
+
+//DoJSON 把in编码为json作为请求体（in为nil时没有请求体），回复2xx时把回复体解码到out中（out为nil时丢弃）
+//其它状态码返回*StatusError
+func (c *Client) DoJSON(ctx context.Context, method, url string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b) //bytes.Reader可以重新读取，请求可以重试
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: b}
+	}
+	if out == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("httpclient: decode response failed, err:%v", err)
+	}
+	return nil
+}
+
+//GetJSON 发送GET请求并把回复解码到out中
+func (c *Client) GetJSON(ctx context.Context, url string, out interface{}) error {
+	return c.DoJSON(ctx, http.MethodGet, url, nil, out)
+}
+
+//PostJSON 以json格式发送in，并把回复解码到out中
+func (c *Client) PostJSON(ctx context.Context, url string, in, out interface{}) error {
+	return c.DoJSON(ctx, http.MethodPost, url, in, out)
+}
@@ -0,0 +1,261 @@
+package httpkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//MaxBodyBytes 请求体的最大字节数，超过时返回413
+var MaxBodyBytes int64 = 1 << 20
+
+//MaxMemory 解析multipart时最多在内存中保存的字节数，超过的部分（上传的文件）保存到临时文件中
+var MaxMemory int64 = 32 << 20
+
+//Decode 根据请求的Content-Type把请求体解码到v中，然后按validate标签校验，v必须是结构体指针
+//  application/json                   按json标签解码，不允许出现结构体中没有的字段
+//  application/x-www-form-urlencoded  按form标签解码
+//  multipart/form-data                按form标签解码，*multipart.FileHeader类型的字段接收上传的文件
+//请求体只能读取一次，所以同一个请求不能既调用r.ParseForm又读取r.Body
+//返回的错误都是*Error，可以直接交给WriteError
+//
+//multipart请求中超过MaxMemory的文件保存在临时文件中，Decode成功后由handler在用完上传的文件后清理：
+//  defer r.MultipartForm.RemoveAll()
+//中间件和路由传给handler的r是r.WithContext的拷贝，net/http只清理它自己的r，看不到拷贝上的MultipartForm；
+//Decode返回错误时已经清理过了，不需要handler处理
+func Decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return Errorf(http.StatusUnsupportedMediaType, "missing or invalid Content-Type")
+	}
+	limit := MaxBodyBytes
+	if mediaType == "multipart/form-data" && MaxMemory > limit {
+		limit = MaxMemory
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	switch mediaType {
+	case "application/json":
+		err = decodeJSON(r.Body, v)
+	case "application/x-www-form-urlencoded":
+		if err = r.ParseForm(); err != nil {
+			err = bodyError(err)
+			break
+		}
+		err = bind(r.PostForm, nil, v)
+	case "multipart/form-data":
+		if err = r.ParseMultipartForm(MaxMemory); err != nil {
+			err = bodyError(err)
+			break
+		}
+		err = bind(r.MultipartForm.Value, r.MultipartForm.File, v)
+		if err == nil {
+			err = Validate(v)
+		}
+		if err != nil {
+			r.MultipartForm.RemoveAll()
+		}
+		return err
+	default:
+		return Errorf(http.StatusUnsupportedMediaType, "unsupported Content-Type %q", mediaType)
+	}
+	if err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+//DecodeQuery 把URL中的参数按form标签解码到v中并校验，用于GET请求
+func DecodeQuery(r *http.Request, v interface{}) error {
+	if err := bind(r.URL.Query(), nil, v); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+//bodyError 读取请求体时的错误，超过MaxBodyBytes时http.MaxBytesReader返回的错误没有单独的类型，只能比较错误信息
+func bodyError(err error) error {
+	if strings.Contains(err.Error(), "request body too large") {
+		return Errorf(http.StatusRequestEntityTooLarge, "request body too large")
+	}
+	return Errorf(http.StatusBadRequest, "invalid request body: %v", err)
+}
+
+func decodeJSON(body io.Reader, v interface{}) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		//请求体中只能有一个json值
+		if dec.More() {
+			return Errorf(http.StatusBadRequest, "request body must contain a single JSON value")
+		}
+		return nil
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == io.EOF:
+		return Errorf(http.StatusBadRequest, "request body is empty")
+	case err == io.ErrUnexpectedEOF:
+		return Errorf(http.StatusBadRequest, "malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return Errorf(http.StatusBadRequest, "malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Message: "invalid field type",
+			Fields:  []FieldError{{Field: typeErr.Field, Message: "must be " + kindName(typeErr.Type)}},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Message: "unknown field",
+			Fields:  []FieldError{{Field: field, Message: "is not allowed"}},
+		}
+	}
+	return bodyError(err)
+}
+
+var fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+
+//bind 按form标签把values和files中的值赋给v的字段，没有form标签时使用json标签，都没有时使用字段名
+func bind(values url.Values, files map[string][]*multipart.FileHeader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("httpkit: want a pointer to struct, got %T", v))
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	var fields []FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" { //未导出的字段
+			continue
+		}
+		name := tagName(f, "form")
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		//上传的文件
+		if f.Type == fileHeaderType {
+			if fhs := files[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs[0]))
+			}
+			continue
+		}
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem() == fileHeaderType {
+			fv.Set(reflect.ValueOf(files[name]))
+			continue
+		}
+
+		vals, ok := values[name]
+		if !ok {
+			continue
+		}
+		if f.Type.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(f.Type, len(vals), len(vals))
+			for j, s := range vals {
+				if err := setValue(slice.Index(j), s); err != nil {
+					fields = append(fields, FieldError{Field: name, Message: err.Error()})
+					break
+				}
+			}
+			fv.Set(slice)
+			continue
+		}
+		if err := setValue(fv, vals[0]); err != nil {
+			fields = append(fields, FieldError{Field: name, Message: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		return &Error{Status: http.StatusBadRequest, Message: "invalid field type", Fields: fields}
+	}
+	return nil
+}
+
+//setValue 把字符串转换成字段的类型后赋值
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be " + kindName(v.Type()))
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be " + kindName(v.Type()))
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be " + kindName(v.Type()))
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be " + kindName(v.Type()))
+		}
+		v.SetFloat(n)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	default:
+		panic(fmt.Sprintf("httpkit: unsupported form field type %s", v.Type()))
+	}
+	return nil
+}
+
+//kindName 错误信息中类型的名字
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Ptr:
+		return kindName(t.Elem())
+	}
+	return t.String()
+}
+
+//tagName 字段在key标签中的名字，没有时依次使用json标签和字段名
+func tagName(f reflect.StructField, key string) string {
+	for _, k := range []string{key, "json"} {
+		if tag, ok := f.Tag.Lookup(k); ok {
+			if name := strings.Split(tag, ",")[0]; name != "" {
+				return name
+			}
+		}
+	}
+	return f.Name
+}
@@ -0,0 +1,57 @@
+package httpkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+//FieldError 某个字段的错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//Error 处理请求时的错误，Status是回复的状态码，以json格式写回客户端：
+//  {"error":"validation failed","fields":[{"field":"age","message":"must be at most 150"}]}
+type Error struct {
+	Status  int          `json:"-"`
+	Message string       `json:"error"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s %s", e.Message, e.Fields[0].Field, e.Fields[0].Message)
+}
+
+//Errorf ...构造函数
+func Errorf(status int, format string, a ...interface{}) *Error {
+	return &Error{Status: status, Message: fmt.Sprintf(format, a...)}
+}
+
+//WriteJSON 把v编码为json写回客户端
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	b, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"internal server error"}` + "\n"))
+		return err
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+//WriteError 把错误写回客户端，*Error按它的状态码返回，其它错误不把细节暴露给客户端，统一返回500
+func WriteError(w http.ResponseWriter, err error) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Status: http.StatusInternalServerError, Message: "internal server error"}
+	}
+	return WriteJSON(w, e.Status, e)
+}
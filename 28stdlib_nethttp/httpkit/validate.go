@@ -0,0 +1,107 @@
+package httpkit
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//Validate 按validate标签校验v的字段，所有不合法的字段一起以422返回，支持的规则（多个用逗号分隔）：
+//  required    不能是零值（空字符串、0、nil、空切片）
+//  min=n       数字不能小于n，字符串的字符数和切片的长度不能小于n
+//  max=n       数字不能大于n，字符串的字符数和切片的长度不能大于n
+//  oneof=a b   只能是列出的值之一
+//比如：
+//  Age int `json:"age" validate:"required,min=1,max=150"`
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	rt := rv.Type()
+	var fields []FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tag := f.Tag.Get("validate")
+		if tag == "" || f.PkgPath != "" {
+			continue
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			if msg := check(rv.Field(i), rule); msg != "" {
+				fields = append(fields, FieldError{Field: tagName(f, "json"), Message: msg})
+				break //每个字段只报告第一个错误
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return &Error{Status: http.StatusUnprocessableEntity, Message: "validation failed", Fields: fields}
+	}
+	return nil
+}
+
+//check 按一条规则检查字段，合法时返回空字符串
+func check(v reflect.Value, rule string) string {
+	name, arg := rule, ""
+	if i := strings.IndexByte(rule, '='); i >= 0 {
+		name, arg = rule[:i], rule[i+1:]
+	}
+	if name == "required" {
+		if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+			return "is required"
+		}
+		return ""
+	}
+	//指针为nil时由required检查，这里只检查指向的值
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("httpkit: invalid rule %q", rule))
+		}
+		n, unit := size(v)
+		if name == "min" && n < limit {
+			return fmt.Sprintf("must be at least %s%s", arg, unit)
+		}
+		if name == "max" && n > limit {
+			return fmt.Sprintf("must be at most %s%s", arg, unit)
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		s := fmt.Sprint(v.Interface())
+		for _, o := range options {
+			if s == o {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	default:
+		panic(fmt.Sprintf("httpkit: unknown rule %q", rule))
+	}
+	return ""
+}
+
+//size min和max比较的值：数字比较值本身，字符串比较字符数，切片比较长度
+func size(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), " items"
+	}
+	panic(fmt.Sprintf("httpkit: min/max not supported on %s", v.Type()))
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CORSOptions 跨域资源共享（CORS）的配置
+type CORSOptions struct {
+	AllowedOrigins   []string //允许的来源，"*"表示全部允许
+	AllowedMethods   []string //预检请求中允许的方法，默认GET、POST、HEAD
+	AllowedHeaders   []string //预检请求中允许的请求头，为空时允许浏览器要求的全部请求头
+	ExposedHeaders   []string //允许浏览器中的js读取的回复头
+	AllowCredentials bool     //是否允许携带cookie
+	MaxAge           time.Duration
+}
+
+//CORS 给跨域请求加上Access-Control-*回复头，并直接回复预检（OPTIONS）请求
+//来源不在AllowedOrigins中时不加任何回复头，由浏览器拦截
+func CORS(opts CORSOptions) Middleware {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+	}
+	allowAll := false
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+	}
+	allowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		for _, o := range opts.AllowedOrigins {
+			if strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" { //不是跨域请求
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if !allowed(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			//允许携带cookie时不能使用*
+			if allowAll && !opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			reqMethod := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || reqMethod == "" { //普通的跨域请求
+				if len(opts.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			//预检请求：浏览器询问是否允许实际的请求，不需要交给handler
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(opts.AllowedHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+			} else if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			if opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
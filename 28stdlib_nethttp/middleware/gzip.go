@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//gzipWriter 在第一次写回复体时才决定是否压缩：状态码没有回复体或handler已经自己编码了时不压缩
+//handler先调用WriteHeader再Write时（比如经过了http.TimeoutHandler），也要等到Write时才能推断Content-Type
+type gzipWriter struct {
+	http.ResponseWriter
+	level   int
+	gz      *gzip.Writer
+	status  int
+	started bool
+}
+
+func (gw *gzipWriter) WriteHeader(code int) {
+	if gw.status == 0 {
+		gw.status = code
+	}
+}
+
+//start 写回复头，p是回复体的第一部分，用来推断Content-Type
+func (gw *gzipWriter) start(p []byte) {
+	gw.started = true
+	if gw.status == 0 {
+		gw.status = http.StatusOK
+	}
+	h := gw.Header()
+	if gw.status >= http.StatusOK && gw.status != http.StatusNoContent && gw.status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && len(p) > 0 {
+		//net/http会根据回复体的前512个字节推断Content-Type，压缩后就推断不出来了，所以在这里先推断
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(p))
+		}
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length") //压缩后长度会变
+		gw.gz, _ = gzip.NewWriterLevel(gw.ResponseWriter, gw.level)
+	}
+	gw.ResponseWriter.WriteHeader(gw.status)
+}
+
+func (gw *gzipWriter) Write(p []byte) (int, error) {
+	if !gw.started {
+		gw.start(p)
+	}
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(p)
+	}
+	return gw.gz.Write(p)
+}
+
+func (gw *gzipWriter) Flush() {
+	if !gw.started {
+		gw.start(nil)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Hijack 还没有开始写回复时可以接管底层的连接，比如websocket升级协议
+func (gw *gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if gw.started {
+		return nil, nil, errors.New("middleware: cannot hijack after the response has started")
+	}
+	hj, ok := gw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("middleware: response writer does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		gw.started = true //接管以后close不再写回复
+	}
+	return conn, rw, err
+}
+
+//Unwrap 返回底层的ResponseWriter，供http.ResponseController使用
+func (gw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+//close handler返回后调用，没有回复体时也要把回复头写出去
+func (gw *gzipWriter) close() {
+	if !gw.started {
+		if gw.status == 0 { //handler什么都没写，交给net/http回复200
+			return
+		}
+		gw.start(nil)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+	}
+}
+
+//Gzip 客户端支持gzip时压缩回复体，level是gzip的压缩级别，比如gzip.DefaultCompression
+func Gzip(level int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipWriter{ResponseWriter: w, level: level}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+//acceptsGzip 请求头Accept-Encoding中是否有gzip，q=0表示不接受
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if p := strings.Replace(param, " ", "", -1); p == "q=0" || p == "q=0.0" || p == "q=0.00" || p == "q=0.000" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
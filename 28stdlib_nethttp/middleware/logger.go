@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+//Logger 用zap记录每个请求的访问日志，5xx记为Error，4xx记为Warn，其它记为Info
+func Logger(logger *zap.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 { //handler什么都没写，net/http会回复200
+				sw.status = http.StatusOK
+			}
+			fields := []zap.Field{
+				zap.String("request_id", GetRequestID(r.Context())),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("query", r.URL.RawQuery),
+				zap.Int("status", sw.status),
+				zap.Int64("bytes", sw.bytes),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("user_agent", r.UserAgent()),
+			}
+			switch {
+			case sw.status >= 500:
+				logger.Error("access", fields...)
+			case sw.status >= 400:
+				logger.Warn("access", fields...)
+			default:
+				logger.Info("access", fields...)
+			}
+		})
+	}
+}
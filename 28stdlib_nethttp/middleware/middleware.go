@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+//Middleware 中间件：包装一个handler，在它前后加上通用的处理逻辑
+type Middleware func(http.Handler) http.Handler
+
+//Chain 把多个中间件组合成一个，第一个在最外层，请求按顺序经过每个中间件：
+//  handler := middleware.Chain(middleware.RequestID, middleware.Logger(logger))(mux)
+func Chain(mws ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+//statusWriter 记录handler写回的状态码和字节数
+//http.Flusher和http.Hijacker交给底层的ResponseWriter处理，否则经过中间件后流式回复和websocket都不能用了
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Hijack 接管底层的连接，比如websocket升级协议，接管以后就不能再通过ResponseWriter回复了
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("middleware: response writer does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil && sw.status == 0 {
+		sw.status = http.StatusSwitchingProtocols //访问日志中记为101
+	}
+	return conn, rw, err
+}
+
+//Unwrap 返回底层的ResponseWriter，http.ResponseController（Go 1.20）通过它找到底层支持的功能
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+//wroteHeader 是否已经开始写回复，开始写以后就不能再修改状态码了
+func (sw *statusWriter) wroteHeader() bool {
+	return sw.status != 0
+}
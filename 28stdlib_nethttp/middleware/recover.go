@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/httpkit"
+	"go.uber.org/zap"
+)
+
+//Recover 捕获handler中的panic，记录日志和调用栈后返回500，不让一个请求的panic打断整个连接
+func Recover(logger *zap.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				//http.ErrAbortHandler表示handler主动中止回复，交给net/http处理
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				logger.Error("panic recovered",
+					zap.String("request_id", GetRequestID(r.Context())),
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("panic", fmt.Sprint(err)),
+					zap.Stack("stack"))
+				//已经开始写回复时无法再修改状态码，只能中止连接
+				if sw.wroteHeader() {
+					panic(http.ErrAbortHandler)
+				}
+				httpkit.WriteError(sw, fmt.Errorf("panic: %v", err))
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+//RequestIDHeader 请求ID所在的请求头和回复头
+const RequestIDHeader = "X-Request-ID"
+
+//contextKey 和29stdlib_context中的TraceCode一样，用自定义类型作为context的key，避免和其它包的key冲突
+type contextKey string
+
+const requestIDKey = contextKey("REQUEST_ID")
+
+//RequestID 给每个请求一个ID，保存在context中，同时写到回复头里
+//请求头中已经带了合法的ID时（比如经过了上游的网关）沿用它，方便把多个服务的日志串起来
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validID(id) {
+			id = newID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+//GetRequestID 从context中取出请求ID，没有时返回空字符串
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+//validID 客户端传来的ID会写进日志和回复头，只接受不太长的字母、数字和-_
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+//timeoutWriter 超时后http.TimeoutHandler回复的503是json，给它加上Content-Type
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && tw.ctx.Err() == context.DeadlineExceeded &&
+		tw.Header().Get("Content-Type") == "" {
+		tw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+//Timeout 限制每个请求的处理时间，超时后回复503
+//handler中的r.Context()会在超时后被取消，耗时的操作应该检查它并及时返回，超时后handler写的回复会被丢弃
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		th := http.TimeoutHandler(next, d, `{"error":"request timeout"}`+"\n")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			th.ServeHTTP(&timeoutWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+		})
+	}
+}
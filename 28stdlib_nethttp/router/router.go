@@ -0,0 +1,183 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/httpkit"
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/middleware"
+)
+
+//Param 路径中的一个参数
+type Param struct {
+	Key   string
+	Value string
+}
+
+//Params 路径中的所有参数，按出现的顺序排列
+type Params []Param
+
+//Get 取出名字为key的参数，没有时返回空字符串
+func (ps Params) Get(key string) string {
+	for _, p := range ps {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+//contextKey 和middleware中的请求ID一样，用自定义类型作为context的key
+type contextKey string
+
+const paramsKey = contextKey("PARAMS")
+
+//GetParams 取出请求路径中的所有参数
+func GetParams(r *http.Request) Params {
+	ps, _ := r.Context().Value(paramsKey).(Params)
+	return ps
+}
+
+//GetParam 取出请求路径中名字为key的参数，比如/users/:id中的id
+func GetParam(r *http.Request, key string) string {
+	return GetParams(r).Get(key)
+}
+
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+//Router 按方法和路径匹配的路由，路径可以包含参数和通配符：
+//  /users/:id          匹配/users/1，id为1
+//  /static/*filepath   匹配/static/css/a.css，filepath为css/a.css
+//路径匹配但方法不匹配时回复405并在Allow中列出允许的方法，GET路由同时处理HEAD请求
+type Router struct {
+	RouteGroup
+	root *node
+
+	//NotFound 没有匹配的路由时调用，默认回复json格式的404
+	NotFound http.Handler
+}
+
+//New ...构造函数
+func New() *Router {
+	r := &Router{root: newNode()}
+	r.RouteGroup = RouteGroup{router: r}
+	return r
+}
+
+//RouteGroup 一组有相同前缀和中间件的路由
+type RouteGroup struct {
+	router      *Router
+	prefix      string
+	middlewares []middleware.Middleware
+}
+
+//Group 创建一个子路由组，前缀和中间件在当前组的基础上追加，中间件只作用于组内匹配到的路由
+func (g *RouteGroup) Group(prefix string, mws ...middleware.Middleware) *RouteGroup {
+	all := make([]middleware.Middleware, 0, len(g.middlewares)+len(mws))
+	all = append(all, g.middlewares...)
+	all = append(all, mws...)
+	return &RouteGroup{
+		router:      g.router,
+		prefix:      g.prefix + "/" + strings.Trim(prefix, "/"),
+		middlewares: all,
+	}
+}
+
+//Use 给组内之后注册的路由追加中间件
+func (g *RouteGroup) Use(mws ...middleware.Middleware) {
+	g.middlewares = append(g.middlewares, mws...)
+}
+
+//Handle 注册一个路由，同一个方法和路径重复注册时panic
+func (g *RouteGroup) Handle(method, pattern string, h http.Handler) {
+	full := g.prefix + "/" + strings.TrimLeft(pattern, "/")
+	method = strings.ToUpper(method)
+	n := g.router.root.insert(full)
+	if old, ok := n.handlers[method]; ok {
+		panic(fmt.Sprintf("router: %s %s conflicts with %s %s", method, full, method, old.pattern))
+	}
+	n.handlers[method] = &route{pattern: full, handler: middleware.Chain(g.middlewares...)(h)}
+}
+
+//HandleFunc ...
+func (g *RouteGroup) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	g.Handle(method, pattern, h)
+}
+
+//GET ...
+func (g *RouteGroup) GET(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodGet, pattern, h)
+}
+
+//POST ...
+func (g *RouteGroup) POST(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodPost, pattern, h)
+}
+
+//PUT ...
+func (g *RouteGroup) PUT(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodPut, pattern, h)
+}
+
+//PATCH ...
+func (g *RouteGroup) PATCH(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodPatch, pattern, h)
+}
+
+//DELETE ...
+func (g *RouteGroup) DELETE(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodDelete, pattern, h)
+}
+
+//allowed 节点上注册了的方法，用于Allow回复头
+func allowed(n *node) string {
+	methods := make([]string, 0, len(n.handlers)+2)
+	for m := range n.handlers {
+		methods = append(methods, m)
+	}
+	if _, ok := n.handlers[http.MethodGet]; ok {
+		if _, ok := n.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	if _, ok := n.handlers[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n, params := rt.root.match(split(r.URL.Path), nil)
+	if n == nil {
+		if rt.NotFound != nil {
+			rt.NotFound.ServeHTTP(w, r)
+			return
+		}
+		httpkit.WriteError(w, httpkit.Errorf(http.StatusNotFound, "%s not found", r.URL.Path))
+		return
+	}
+	rte, ok := n.handlers[r.Method]
+	if !ok && r.Method == http.MethodHead {
+		rte, ok = n.handlers[http.MethodGet]
+	}
+	if !ok {
+		w.Header().Set("Allow", allowed(n))
+		if r.Method == http.MethodOptions { //没有注册OPTIONS时回复允许的方法
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		httpkit.WriteError(w, httpkit.Errorf(http.StatusMethodNotAllowed, "method %s not allowed", r.Method))
+		return
+	}
+	if len(params) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey, params))
+	}
+	rte.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,102 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+)
+
+//node 路由树的一个节点，对应路径中的一段
+//匹配时静态的段优先，其次是:name参数，最后是*name通配符，比如/users/new优先于/users/:id
+type node struct {
+	static   map[string]*node
+	param    *node
+	wildcard *node
+	name     string //参数或通配符的名字
+
+	handlers map[string]*route //方法 -> 路由
+}
+
+func newNode() *node {
+	return &node{static: make(map[string]*node), handlers: make(map[string]*route)}
+}
+
+//split 把路径按/分成多段，忽略空的段，所以/users/和/users是同一个路径
+func split(path string) []string {
+	segs := make([]string, 0, 8)
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+//insert 按pattern找到或创建节点，同一个位置的参数名字不同、通配符不在最后时panic
+func (n *node) insert(pattern string) *node {
+	segs := split(pattern)
+	for i, seg := range segs {
+		switch seg[0] {
+		case ':':
+			name := seg[1:]
+			if name == "" {
+				panic(fmt.Sprintf("router: empty param name in %q", pattern))
+			}
+			if n.param == nil {
+				n.param = newNode()
+				n.param.name = name
+			} else if n.param.name != name {
+				panic(fmt.Sprintf("router: param :%s in %q conflicts with :%s", name, pattern, n.param.name))
+			}
+			n = n.param
+		case '*':
+			name := seg[1:]
+			if name == "" || i != len(segs)-1 {
+				panic(fmt.Sprintf("router: wildcard must be named and at the end of %q", pattern))
+			}
+			if n.wildcard == nil {
+				n.wildcard = newNode()
+				n.wildcard.name = name
+			} else if n.wildcard.name != name {
+				panic(fmt.Sprintf("router: wildcard *%s in %q conflicts with *%s", name, pattern, n.wildcard.name))
+			}
+			n = n.wildcard
+		default:
+			child, ok := n.static[seg]
+			if !ok {
+				child = newNode()
+				n.static[seg] = child
+			}
+			n = child
+		}
+	}
+	return n
+}
+
+//match 查找和路径匹配并且注册了路由的节点，匹配到的参数追加到params中
+//优先的分支匹配失败时回溯，尝试下一种分支
+func (n *node) match(segs []string, params Params) (*node, Params) {
+	if len(segs) == 0 {
+		if len(n.handlers) > 0 {
+			return n, params
+		}
+		//通配符可以匹配空的路径，比如/static/*filepath匹配/static/
+		if n.wildcard != nil && len(n.wildcard.handlers) > 0 {
+			return n.wildcard, append(params, Param{Key: n.wildcard.name, Value: ""})
+		}
+		return nil, params
+	}
+	if child, ok := n.static[segs[0]]; ok {
+		if found, p := child.match(segs[1:], params); found != nil {
+			return found, p
+		}
+	}
+	if n.param != nil {
+		if found, p := n.param.match(segs[1:], append(params, Param{Key: n.param.name, Value: segs[0]})); found != nil {
+			return found, p
+		}
+	}
+	if n.wildcard != nil && len(n.wildcard.handlers) > 0 {
+		return n.wildcard, append(params, Param{Key: n.wildcard.name, Value: strings.Join(segs, "/")})
+	}
+	return nil, params
+}
@@ -1,47 +1,112 @@
 package main
 
 import (
+	"compress/gzip"
 	"fmt"
-	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"time"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/httpkit"
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/middleware"
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/router"
+	"github.com/Moqqll/02goLearning/31zapgo-logger/zaplog"
+	"go.uber.org/zap/zapcore"
 )
 
+//user 请求中的用户信息，form标签用于URL参数和表单，json标签用于json请求体，validate标签用于校验
+type user struct {
+	Name   string                `form:"name" json:"name" validate:"required,max=32"`
+	Age    int                   `form:"age" json:"age" validate:"min=0,max=150"`
+	Avatar *multipart.FileHeader `form:"avatar" json:"-"` //multipart请求中上传的头像，可选
+}
+
 func getHandler(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-	data := r.URL.Query()
-	fmt.Println(data.Get("name"))
-	fmt.Println(data.Get("age"))
-	answer := `{"status":"ok"}`
-	w.Write([]byte(answer))
+	var u user
+	if err := httpkit.DecodeQuery(r, &u); err != nil {
+		httpkit.WriteError(w, err)
+		return
+	}
+	fmt.Println(u.Name, u.Age)
+	httpkit.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "user": u})
 }
 
 func postHandler(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-	//请求类型是application/x-www-form-urlencoded时解析form数据
-	r.ParseForm()
-	fmt.Println(r.PostForm) //打印form数据
-	fmt.Println(r.PostForm.Get("name"), r.PostForm.Get("age"))
-
-	//请求类型是application/json时从r.Body读取数据
-	b, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		fmt.Printf("read request.Body failed, err:%v\n", err)
+	//按Content-Type解码请求体：application/x-www-form-urlencoded、application/json或multipart/form-data
+	//请求体只能读取一次，以前先r.ParseForm()再ioutil.ReadAll(r.Body)，json请求体可能已经被读过了
+	var u user
+	if err := httpkit.Decode(w, r, &u); err != nil {
+		fmt.Printf("decode request failed, err:%v\n", err)
+		httpkit.WriteError(w, err)
 		return
 	}
-	fmt.Println(string(b))
-	answer := `{"status":"ok"}`
-	w.Write([]byte(answer))
+	//r是中间件传下来的拷贝，net/http不会清理这个拷贝上传的临时文件
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	fmt.Println(u.Name, u.Age)
+	resp := map[string]interface{}{"status": "ok", "user": u}
+	if u.Avatar != nil {
+		resp["avatar"] = map[string]interface{}{"filename": u.Avatar.Filename, "size": u.Avatar.Size}
+	}
+	httpkit.WriteJSON(w, http.StatusOK, resp)
 }
 
 func sayHello(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "Hello,张雅婷！")
 }
 
+//helloHandler 路径中带参数，/hello/:name
+func helloHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "Hello,%s！\n", router.GetParam(r, "name"))
+}
+
+//slowHandler 模拟耗时的操作，超过Timeout中间件的时间后会收到503
+func slowHandler(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-time.After(3 * time.Second):
+		httpkit.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case <-r.Context().Done(): //超时后ctx被取消，不用再继续处理了
+		fmt.Printf("request %s canceled, err:%v\n", middleware.GetRequestID(r.Context()), r.Context().Err())
+	}
+}
+
+//panicHandler 模拟handler中的panic，Recover中间件会回复500
+func panicHandler(w http.ResponseWriter, r *http.Request) {
+	var m map[string]int
+	m["boom"]++
+}
+
 func main() {
-	http.HandleFunc("/get", getHandler)
-	http.HandleFunc("/post", postHandler)
-	http.HandleFunc("/", sayHello)
-	err := http.ListenAndServe("127.0.0.1:9000", nil)
+	//使用31zapgo-logger中的logger配置，访问日志输出到标准输出
+	logger := zaplog.New(zapcore.AddSync(os.Stdout), zapcore.InfoLevel)
+	defer logger.Sync()
+
+	//DefaultServeMux按前缀匹配，也不区分方法，换成按方法和路径匹配的router
+	r := router.New()
+	r.GET("/", sayHello)
+	r.GET("/get", getHandler)
+	r.POST("/post", postHandler)
+	r.GET("/hello/:name", helloHandler)
+	r.GET("/panic", panicHandler)
+	//路由组中的中间件只作用于组内的路由，这里只有慢请求有超时限制
+	slow := r.Group("/", middleware.Timeout(2*time.Second))
+	slow.GET("/slow", slowHandler)
+
+	//中间件按顺序执行：先分配请求ID，日志中才能带上它；Recover在Logger里面，日志中能看到panic时回复的500
+	handler := middleware.Chain(
+		middleware.RequestID,
+		middleware.Logger(logger),
+		middleware.Recover(logger),
+		middleware.CORS(middleware.CORSOptions{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+			MaxAge:         10 * time.Minute,
+		}),
+		middleware.Gzip(gzip.DefaultCompression),
+	)(r)
+	err := http.ListenAndServe("127.0.0.1:9000", handler)
 	if err != nil {
 		fmt.Printf("http server start failed, err:%v\n", err)
 	}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/httpclient"
+)
+
+//server端有一半的请求要10s才回复，这里有两种办法不被慢请求拖住：
+//  对冲请求（hedged request）：threshold内没有回复时再发一个相同的请求，取第一个成功的回复，取消其它的
+//  扇出（fan-out）：同时请求多个地址，共用一个截止时间，返回已经完成的部分结果和每个地址的错误
+
+//reply 一个请求的结果，回复体在goroutine中读完，取消ctx后不会影响已经返回的结果
+type reply struct {
+	url      string
+	attempt  int
+	status   int
+	body     string
+	err      error
+	duration time.Duration
+}
+
+//fetch 发送一个GET请求并读完回复体，状态码不是200时也算失败
+func fetch(ctx context.Context, c *httpclient.Client, url string, attempt int) *reply {
+	start := time.Now()
+	r := &reply{url: url, attempt: attempt}
+	defer func() { r.duration = time.Since(start) }()
+	resp, err := c.Get(ctx, url)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	defer resp.Body.Close()
+	r.status = resp.StatusCode
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.body = string(b)
+	if resp.StatusCode != http.StatusOK {
+		r.err = fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return r
+}
+
+//hedgedGet 对冲请求：先发一个请求，每过threshold还没有成功的回复就再发一个，最多同时发max个
+//某个请求失败时不等threshold，立即补发下一个；返回第一个成功的回复，返回前取消其它还没完成的请求
+//max小于1时按1处理
+func hedgedGet(ctx context.Context, c *httpclient.Client, url string, threshold time.Duration, max int) (*reply, int, error) {
+	if max < 1 {
+		max = 1 //replies的缓冲必须能放下所有请求的结果，否则返回后还在运行的goroutine会一直阻塞
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() //取消其它还没完成的请求
+
+	replies := make(chan *reply, max) //有缓冲，返回后还在运行的goroutine也不会阻塞
+	sent, failed := 0, 0
+	send := func() {
+		sent++
+		go func(attempt int) {
+			replies <- fetch(ctx, c, url, attempt)
+		}(sent)
+	}
+	send()
+	timer := time.NewTimer(threshold)
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if sent < max {
+				send()
+				timer.Reset(threshold)
+			}
+		case r := <-replies:
+			if r.err == nil {
+				return r, sent, nil
+			}
+			failed++
+			lastErr = r.err
+			if sent < max {
+				send()
+			} else if failed == sent { //都失败了
+				return nil, sent, lastErr
+			}
+		case <-ctx.Done():
+			return nil, sent, ctx.Err()
+		}
+	}
+}
+
+//fanOut 同时请求所有的地址，共用ctx中的截止时间，结果的顺序和urls相同
+//截止时间到了还没完成的请求返回ctx的错误，已经完成的结果照常返回
+func fanOut(ctx context.Context, c *httpclient.Client, urls []string) []*reply {
+	replies := make([]*reply, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			replies[i] = fetch(ctx, c, url, 1) //每个goroutine只写自己的下标，不需要加锁
+		}(i, url)
+	}
+	wg.Wait()
+	return replies
+}
@@ -2,69 +2,100 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/httpclient"
 )
 
-type respData struct {
-	resp *http.Response
-	err  error
-}
+var (
+	url        = flag.String("url", "http://127.0.0.1:9000", "请求的地址，可以指向ex/faultproxy来测试各种故障")
+	timeout    = flag.Duration("timeout", time.Millisecond*100, "请求的超时时间")
+	mode       = flag.String("mode", "single", "single：发送一个请求，hedge：对冲请求，fanout：同时请求urls中的所有地址")
+	hedgeAfter = flag.Duration("hedge-after", 20*time.Millisecond, "hedge模式下多久没有回复就再发一个请求")
+	hedgeMax   = flag.Int("hedge-max", 3, "hedge模式下最多发送的请求数")
+	urls       = flag.String("urls", "http://127.0.0.1:9000,http://127.0.0.1:9000,http://127.0.0.1:9001", "fanout模式下请求的地址，多个用逗号分隔")
+)
+
+//client 复用连接的客户端，超时用ctx控制，不需要再自己启动goroutine和select
+var client = httpclient.New()
 
 func doCall(ctx context.Context) {
-	//新建一个client
-	transport := http.Transport{
-		DisableKeepAlives: true,
+	resp, err := client.Get(ctx, *url)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Println("call api timeout, err:", err)
+			return
+		}
+		fmt.Println("call server api failed, err:", err)
+		return
 	}
-	client := http.Client{
-		Transport: &transport,
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println("call server api failed, status:", resp.Status)
+		return
 	}
-
-	//封装request
-	respChan := make(chan *respData, 1)
-	req, err := http.NewRequest("GET", "http://127.0.0.1:9000", nil)
+	fmt.Println("call server api success")
+	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("new request failed, err:%v\n", err)
+		fmt.Println("read resp body failed, err:", err)
 		return
 	}
-	req = req.WithContext(ctx) //使用带超时的ctx创建一个新的client request
+	fmt.Printf("resp data:%v\n", string(data))
+}
 
-	//开始进行异步request
-	var wg sync.WaitGroup
-	wg.Add(1)
-	defer wg.Wait()
-	go func() {
-		resp, err := client.Do(req)
-		fmt.Printf("client.do resp:%v, err:%v\n", resp, err)
-		rd := &respData{
-			resp: resp,
-			err:  err,
-		}
-		respChan <- rd
-		wg.Done()
-	}()
+func doHedged(ctx context.Context) {
+	//对冲请求本身就是一种重试，每个请求不再单独重试
+	c := httpclient.New()
+	c.MaxRetries = 0
+	start := time.Now()
+	r, sent, err := hedgedGet(ctx, c, *url, *hedgeAfter, *hedgeMax)
+	if err != nil {
+		fmt.Printf("hedged request failed after %d requests, err:%v\n", sent, err)
+		return
+	}
+	fmt.Printf("hedged request: request %d of %d won in %v, total %v\n",
+		r.attempt, sent, r.duration.Round(time.Millisecond), time.Since(start).Round(time.Millisecond))
+	fmt.Printf("resp data:%v\n", r.body)
+}
 
-	select {
-	case <-ctx.Done():
-		fmt.Println("call api timeout")
-	case result := <-respChan:
-		fmt.Println("call server api success")
-		if result.err != nil {
-			fmt.Println("call server api data failed, err:", err)
-			return
+func doFanOut(ctx context.Context) {
+	c := httpclient.New()
+	c.MaxRetries = 0
+	start := time.Now()
+	replies := fanOut(ctx, c, strings.Split(*urls, ","))
+	ok := 0
+	for i, r := range replies {
+		if r.err != nil {
+			fmt.Printf("%d %s failed after %v, err:%v\n", i, r.url, r.duration.Round(time.Millisecond), r.err)
+			continue
 		}
-		defer result.resp.Body.Close()
-		data, _ := ioutil.ReadAll(result.resp.Body)
-		fmt.Printf("resp data:%v\n", string(data))
+		ok++
+		fmt.Printf("%d %s %d %q in %v\n", i, r.url, r.status, r.body, r.duration.Round(time.Millisecond))
 	}
+	fmt.Printf("fan-out: %d/%d succeeded in %v\n", ok, len(replies), time.Since(start).Round(time.Millisecond))
 }
 
 func main() {
-	//定义一个100ms的超时
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	flag.Parse()
+	if *mode == "hedge" && *hedgeMax < 1 {
+		fmt.Println("hedge-max must be at least 1")
+		return
+	}
+	//定义一个超时，默认100ms
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
-	doCall(ctx)
+	switch *mode {
+	case "hedge":
+		doHedged(ctx)
+	case "fanout":
+		doFanOut(ctx)
+	default:
+		doCall(ctx)
+	}
 }
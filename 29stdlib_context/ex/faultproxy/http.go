@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+//http模式：对每个请求依次注入延迟、连接重置、5xx错误，剩下的请求转发给上游，回复按带宽限速
+
+//throttledWriter 按带宽限速写回复，每写一小块就等待一段时间
+type throttledWriter struct {
+	http.ResponseWriter
+	f *faults
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	chunk := tw.f.cfg.Bandwidth / 10 //每秒大约写10次
+	if chunk < 1 {
+		chunk = 1
+	}
+	written := 0
+	for written < len(p) {
+		end := written + chunk
+		if end > len(p) {
+			end = len(p)
+		}
+		n, err := tw.ResponseWriter.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		tw.Flush()
+		time.Sleep(tw.f.throttle(n))
+	}
+	return written, nil
+}
+
+//Flush 限速时需要立即发送，客户端才能看到数据是慢慢到达的
+func (tw *throttledWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//resetHTTP 接管底层的TCP连接，设置SO_LINGER为0后关闭，客户端会收到RST（connection reset by peer）
+func resetHTTP(w http.ResponseWriter) error {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return err
+	}
+	return resetConn(conn)
+}
+
+//resetConn 以RST的方式关闭连接，而不是正常的FIN
+func resetConn(conn net.Conn) error {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	return conn.Close()
+}
+
+//faultsKey 在请求的context中保存连接的故障生成器
+type faultsKey struct{}
+
+//faultHandler 注入故障后再转发给上游
+func faultHandler(in *injector, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		//同一个连接上的请求是依次处理的，可以共用一个故障生成器
+		f := r.Context().Value(faultsKey{}).(*faults)
+		if d := f.delay(); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done(): //客户端已经超时放弃了
+				fmt.Printf("%s %s client gone after %v\n", r.Method, r.URL, time.Since(start).Round(time.Millisecond))
+				return
+			}
+		}
+		if f.chance(in.cfg.ResetRate) {
+			if err := resetHTTP(w); err != nil {
+				fmt.Printf("reset connection failed, err:%v\n", err)
+			}
+			fmt.Printf("%s %s -> injected reset after %v\n", r.Method, r.URL, time.Since(start).Round(time.Millisecond))
+			return
+		}
+		if f.chance(in.cfg.ErrorRate) {
+			http.Error(w, "injected fault", in.cfg.ErrorStatus)
+			fmt.Printf("%s %s -> injected %d after %v\n", r.Method, r.URL, in.cfg.ErrorStatus, time.Since(start).Round(time.Millisecond))
+			return
+		}
+		if in.cfg.Bandwidth > 0 {
+			w = &throttledWriter{ResponseWriter: w, f: f}
+		}
+		next.ServeHTTP(w, r)
+		fmt.Printf("%s %s -> proxied in %v\n", r.Method, r.URL, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+func serveHTTP(in *injector) error {
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: in.cfg.Upstream})
+	proxy.FlushInterval = -1 //每次写完立即发送给客户端，限速才能生效
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		fmt.Printf("%s %s proxy failed, err:%v\n", r.Method, r.URL, err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	server := &http.Server{
+		Addr:    in.cfg.Listen,
+		Handler: faultHandler(in, proxy),
+		//ConnContext在接受连接的goroutine中按顺序调用，给每个连接分配自己的故障生成器
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, faultsKey{}, in.forConn())
+		},
+	}
+	return server.ListenAndServe()
+}
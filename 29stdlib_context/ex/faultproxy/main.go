@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+//故障注入代理：插在client和server之间，按配置注入延迟、限速、连接重置和HTTP 5xx错误，
+//用来测试client的超时逻辑，比server中随机sleep 10s更可控，指定seed后按同样的顺序建立的连接注入的故障都相同
+//  go run ./ex/faultproxy --listen 127.0.0.1:9001 --upstream 127.0.0.1:9000 --latency 80ms --jitter 40ms --seed 1
+//  go run ./ex/client --url http://127.0.0.1:9001
+
+//duration 可以在json中写成"100ms"这样的字符串
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration should be a string like \"100ms\", err:%v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+//config 代理的配置，可以从json文件中加载，命令行中指定的参数会覆盖文件中的配置
+type config struct {
+	Mode        string   `json:"mode"` //http或tcp
+	Listen      string   `json:"listen"`
+	Upstream    string   `json:"upstream"`
+	Latency     duration `json:"latency"`      //固定延迟
+	Jitter      duration `json:"jitter"`       //在固定延迟的基础上再随机增加[0, jitter)的延迟
+	Bandwidth   int      `json:"bandwidth"`    //每秒最多传输的字节数，0表示不限速
+	ResetRate   float64  `json:"reset_rate"`   //重置连接的百分比，0~100
+	ErrorRate   float64  `json:"error_rate"`   //http模式下直接返回错误的百分比，0~100
+	ErrorStatus int      `json:"error_status"` //注入的错误的状态码
+	Seed        int64    `json:"seed"`         //随机数种子，0表示使用当前时间
+}
+
+//check 检查配置是否合法
+func (c *config) check() error {
+	if c.Mode != "http" && c.Mode != "tcp" {
+		return fmt.Errorf("invalid mode %q, want http or tcp", c.Mode)
+	}
+	if c.Upstream == "" {
+		return fmt.Errorf("upstream is required")
+	}
+	if c.Latency < 0 || c.Jitter < 0 || c.Bandwidth < 0 {
+		return fmt.Errorf("latency, jitter and bandwidth must not be negative")
+	}
+	if c.ResetRate < 0 || c.ResetRate > 100 || c.ErrorRate < 0 || c.ErrorRate > 100 {
+		return fmt.Errorf("reset_rate and error_rate must be between 0 and 100")
+	}
+	if c.Mode == "tcp" && c.ErrorRate > 0 {
+		return fmt.Errorf("error_rate is only supported in http mode")
+	}
+	if c.ErrorStatus < 500 || c.ErrorStatus > 599 {
+		return fmt.Errorf("error_status must be a 5xx status code")
+	}
+	return nil
+}
+
+//loadConfig 先加载默认值，再加载json文件，最后用命令行中指定了的参数覆盖
+func loadConfig() (*config, error) {
+	c := &config{
+		Mode:        "http",
+		Listen:      "127.0.0.1:9001",
+		Upstream:    "127.0.0.1:9000",
+		ErrorStatus: 503,
+	}
+	var (
+		file        = flag.String("config", "", "json配置文件")
+		mode        = flag.String("mode", c.Mode, "代理模式：http或tcp")
+		listen      = flag.String("listen", c.Listen, "监听的地址")
+		upstream    = flag.String("upstream", c.Upstream, "上游地址")
+		latency     = flag.Duration("latency", 0, "固定延迟")
+		jitter      = flag.Duration("jitter", 0, "在固定延迟的基础上再随机增加[0, jitter)的延迟")
+		bandwidth   = flag.Int("bandwidth", 0, "每秒最多传输的字节数，0表示不限速")
+		resetRate   = flag.Float64("reset-rate", 0, "重置连接的百分比，0~100")
+		errorRate   = flag.Float64("error-rate", 0, "http模式下直接返回错误的百分比，0~100")
+		errorStatus = flag.Int("error-status", c.ErrorStatus, "注入的错误的状态码")
+		seed        = flag.Int64("seed", 0, "随机数种子，0表示使用当前时间")
+	)
+	flag.Parse()
+	if *file != "" {
+		b, err := ioutil.ReadFile(*file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("parse %s failed, err:%v", *file, err)
+		}
+	}
+	//只覆盖命令行中指定了的参数
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mode":
+			c.Mode = *mode
+		case "listen":
+			c.Listen = *listen
+		case "upstream":
+			c.Upstream = *upstream
+		case "latency":
+			c.Latency = duration(*latency)
+		case "jitter":
+			c.Jitter = duration(*jitter)
+		case "bandwidth":
+			c.Bandwidth = *bandwidth
+		case "reset-rate":
+			c.ResetRate = *resetRate
+		case "error-rate":
+			c.ErrorRate = *errorRate
+		case "error-status":
+			c.ErrorStatus = *errorStatus
+		case "seed":
+			c.Seed = *seed
+		}
+	})
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	return c, nil
+}
+
+//injector 根据配置决定每次注入什么故障
+//每个连接使用自己的随机数生成器，种子按接受连接的顺序从seed派生，
+//这样并发的连接不会互相影响，seed相同时第n个连接注入的故障总是相同的
+type injector struct {
+	cfg   *config
+	mu    sync.Mutex
+	seeds *rand.Rand //生成每个连接的种子
+}
+
+func newInjector(cfg *config) *injector {
+	return &injector{
+		cfg:   cfg,
+		seeds: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+//forConn 为新接受的连接创建故障生成器，需要按接受连接的顺序调用
+func (in *injector) forConn() *faults {
+	in.mu.Lock()
+	seed := in.seeds.Int63()
+	in.mu.Unlock()
+	return newFaults(in.cfg, seed)
+}
+
+//faults 一个连接的故障生成器，只能在一个goroutine中使用，所以不需要加锁
+type faults struct {
+	cfg *config
+	rnd *rand.Rand
+}
+
+func newFaults(cfg *config, seed int64) *faults {
+	return &faults{
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+//split 派生一个新的故障生成器，交给另一个goroutine使用
+func (f *faults) split() *faults {
+	return newFaults(f.cfg, f.rnd.Int63())
+}
+
+//chance 以percent%的概率返回true
+func (f *faults) chance(percent float64) bool {
+	if percent <= 0 {
+		return false
+	}
+	return f.rnd.Float64()*100 < percent
+}
+
+//delay 本次的延迟：固定延迟+随机抖动
+func (f *faults) delay() time.Duration {
+	d := time.Duration(f.cfg.Latency)
+	if f.cfg.Jitter > 0 {
+		d += time.Duration(f.rnd.Int63n(int64(f.cfg.Jitter)))
+	}
+	return d
+}
+
+//throttle 按带宽限制计算传输n个字节后需要等待的时间
+func (f *faults) throttle(n int) time.Duration {
+	if f.cfg.Bandwidth <= 0 || n <= 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second / time.Duration(f.cfg.Bandwidth)
+}
+
+func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	in := newInjector(cfg)
+	fmt.Printf("%s fault proxy %s -> %s, latency:%v jitter:%v bandwidth:%dB/s reset:%.1f%% error:%.1f%%(%d) seed:%d\n",
+		cfg.Mode, cfg.Listen, cfg.Upstream, time.Duration(cfg.Latency), time.Duration(cfg.Jitter), cfg.Bandwidth,
+		cfg.ResetRate, cfg.ErrorRate, cfg.ErrorStatus, cfg.Seed)
+	if cfg.Mode == "tcp" {
+		err = serveTCP(in)
+	} else {
+		err = serveHTTP(in)
+	}
+	if err != nil {
+		fmt.Printf("serve failed, err:%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+//tcp模式：按reset_rate直接重置新连接（不支持error_rate），其余连接转发给上游，每次转发的数据块都会延迟并按带宽限速
+
+//copyWithFaults 从src读取数据写到dst，每个数据块写之前延迟，写之后按带宽等待
+func copyWithFaults(f *faults, dst, src net.Conn) (int64, error) {
+	chunk := 32 * 1024
+	if f.cfg.Bandwidth > 0 && f.cfg.Bandwidth/10 < chunk {
+		chunk = f.cfg.Bandwidth / 10
+		if chunk < 1 {
+			chunk = 1
+		}
+	}
+	buf := make([]byte, chunk)
+	var written int64
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			time.Sleep(f.delay())
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return written, werr
+			}
+			written += int64(n)
+			time.Sleep(f.throttle(n))
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+//closeWrite 关闭写端，让对端读到EOF
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+		return
+	}
+	conn.Close()
+}
+
+func handleTCP(f *faults, client net.Conn) {
+	defer client.Close()
+	start := time.Now()
+	if f.chance(f.cfg.ResetRate) {
+		time.Sleep(f.delay())
+		resetConn(client)
+		fmt.Printf("%s -> injected reset\n", client.RemoteAddr())
+		return
+	}
+	server, err := net.DialTimeout("tcp", f.cfg.Upstream, 5*time.Second)
+	if err != nil {
+		fmt.Printf("%s dial upstream failed, err:%v\n", client.RemoteAddr(), err)
+		return
+	}
+	defer server.Close()
+
+	//两个方向在不同的goroutine中复制，各自使用派生出来的故障生成器
+	up, down := f.split(), f.split()
+	done := make(chan int64)
+	go func() {
+		n, err := copyWithFaults(up, server, client)
+		if err != nil {
+			server.Close()
+		} else {
+			closeWrite(server)
+		}
+		done <- n
+	}()
+	received, err := copyWithFaults(down, client, server)
+	if err != nil {
+		client.Close()
+	} else {
+		closeWrite(client)
+	}
+	sent := <-done
+	fmt.Printf("%s closed, sent:%d bytes received:%d bytes duration:%v\n",
+		client.RemoteAddr(), sent, received, time.Since(start).Round(time.Millisecond))
+}
+
+func serveTCP(in *injector) error {
+	listener, err := net.Listen("tcp", in.cfg.Listen)
+	if err != nil {
+		return err
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handleTCP(in.forConn(), conn) //按接受的顺序派生种子
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"net/http"
 	"time"
+
+	"github.com/Moqqll/02goLearning/28stdlib_nethttp/router"
 )
 
 //随机出现慢响应
@@ -19,8 +21,9 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	http.HandleFunc("/", indexHandler)
-	err := http.ListenAndServe(":9000", nil)
+	r := router.New()
+	r.GET("/", indexHandler)
+	err := http.ListenAndServe(":9000", r)
 	if err != nil {
 		panic(err)
 	}
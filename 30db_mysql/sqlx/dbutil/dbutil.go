@@ -0,0 +1,76 @@
+package dbutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//sqlx的公共数据层：连接数据库、事务、批量插入
+//main.go中的示例和11stuManagerSystem的mysqlStore都使用这里的函数
+
+//Connect 连接数据库并设置连接池，连接不成功时返回错误
+//也可以使用sqlx.MustConnect，连接不成功就panic
+func Connect(driverName, dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.Connect(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(10)
+	return db, nil
+}
+
+//Transaction 开启事务并调用fn，fn返回错误或panic时回滚，否则提交
+func Transaction(db *sqlx.DB, fn func(tx *sqlx.Tx) error) (err error) {
+	tx, err := db.Beginx() //开启事务
+	if err != nil {
+		return err
+	}
+	//注册  事务提交
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p) //re-throw panic after Rollback
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit() //err is nil, if Commit returns error, update err.
+		}
+	}()
+	return fn(tx)
+}
+
+//BatchInsert 自行拼接批量插入语句，每batchSize行执行一次，防止拼出来的语句占位符过多
+//rows中每一行的值要与columns一一对应，batchSize<=0时一次插入所有行
+func BatchInsert(e sqlx.Execer, table string, columns []string, rows [][]interface{}, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = len(rows)
+	}
+	//此处占位符要与插入值的个数对应
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		//存放(?,?)的slice
+		valueStrings := make([]string, 0, end-start)
+		//存放values的slice
+		valueArgs := make([]interface{}, 0, (end-start)*len(columns))
+		for _, row := range rows[start:end] {
+			if len(row) != len(columns) {
+				return fmt.Errorf("insert into %s failed, want %d values, got %d", table, len(columns), len(row))
+			}
+			valueStrings = append(valueStrings, placeholder)
+			valueArgs = append(valueArgs, row...)
+		}
+		//自行拼接要执行的具体语句
+		sqlStr := fmt.Sprintf("insert into %s (%s) values %s", table, strings.Join(columns, ","), strings.Join(valueStrings, ","))
+		if _, err := e.Exec(sqlStr, valueArgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
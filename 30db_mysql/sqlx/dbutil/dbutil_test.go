@@ -0,0 +1,127 @@
+package dbutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//openTestDB 打开一个内存中的sqlite数据库，并创建testuser表
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Connect failed, err:%v", err)
+	}
+	//内存数据库每个连接都是独立的，只使用一个连接
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("create table testuser (id integer primary key, name varchar(20) not null, age int not null)"); err != nil {
+		t.Fatalf("create table failed, err:%v", err)
+	}
+	return db
+}
+
+func countUsers(t *testing.T, db *sqlx.DB) int {
+	t.Helper()
+	var n int
+	if err := db.Get(&n, "select count(*) from testuser"); err != nil {
+		t.Fatalf("count failed, err:%v", err)
+	}
+	return n
+}
+
+func TestTransaction(t *testing.T) {
+	errFn := errors.New("fn failed")
+	tests := []struct {
+		name    string
+		fnErr   error
+		doPanic bool
+		want    int //事务结束后表中的行数
+	}{
+		{"commit", nil, false, 1},
+		{"rollback on error", errFn, false, 0},
+		{"rollback on panic", nil, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			var err error
+			func() {
+				defer func() {
+					if p := recover(); p != nil && !tt.doPanic {
+						t.Fatalf("unexpected panic:%v", p)
+					}
+				}()
+				err = Transaction(db, func(tx *sqlx.Tx) error {
+					if _, err := tx.Exec("insert into testuser (name, age) values (?, ?)", "a", 18); err != nil {
+						return err
+					}
+					if tt.doPanic {
+						panic("boom")
+					}
+					return tt.fnErr
+				})
+				if tt.doPanic {
+					t.Fatalf("Transaction did not re-throw the panic")
+				}
+			}()
+			if err != tt.fnErr {
+				t.Fatalf("Transaction err = %v, want %v", err, tt.fnErr)
+			}
+			if got := countUsers(t, db); got != tt.want {
+				t.Fatalf("rows = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchInsert(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      int
+		batchSize int
+	}{
+		{"no rows", 0, 2},
+		{"one batch", 2, 0},
+		{"exact batches", 4, 2},
+		{"partial last batch", 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			rows := make([][]interface{}, 0, tt.rows)
+			for i := 0; i < tt.rows; i++ {
+				rows = append(rows, []interface{}{i + 1, "user", 18 + i})
+			}
+			if err := BatchInsert(db, "testuser", []string{"id", "name", "age"}, rows, tt.batchSize); err != nil {
+				t.Fatalf("BatchInsert failed, err:%v", err)
+			}
+			if got := countUsers(t, db); got != tt.rows {
+				t.Fatalf("rows = %d, want %d", got, tt.rows)
+			}
+			var age int
+			if tt.rows > 0 {
+				if err := db.Get(&age, "select age from testuser where id = ?", tt.rows); err != nil {
+					t.Fatalf("get failed, err:%v", err)
+				}
+				if age != 18+tt.rows-1 {
+					t.Fatalf("age = %d, want %d", age, 18+tt.rows-1)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchInsertWrongValues(t *testing.T) {
+	db := openTestDB(t)
+	rows := [][]interface{}{{"a", 18}, {"b"}}
+	if err := BatchInsert(db, "testuser", []string{"name", "age"}, rows, 0); err == nil {
+		t.Fatalf("BatchInsert with a short row succeeded, want error")
+	}
+	if got := countUsers(t, db); got != 0 {
+		t.Fatalf("rows = %d, want 0", got)
+	}
+}
@@ -5,5 +5,6 @@ go 1.14
 require (
 	github.com/go-sql-driver/mysql v1.4.0
 	github.com/jmoiron/sqlx v1.2.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	google.golang.org/appengine v1.6.7 // indirect
 )
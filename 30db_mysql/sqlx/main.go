@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Moqqll/02goLearning/30db_mysql/sqlx/dbutil"
 	_ "github.com/go-sql-driver/mysql" //匿名导入，进初始化
 	"github.com/jmoiron/sqlx"
 )
@@ -36,15 +37,12 @@ func (u Testuser) Value() (driver.Value, error) {
 
 func initDB() (err error) {
 	dsn := "wancheng:wancheng@tcp(127.0.0.1:3306)/sql_test?charset=utf8mb4&parseTime=True"
-	//也可以使用MustConnect，连接不成功就panic
-	dbConn, err = sqlx.Connect("mysql", dsn)
+	//连接并设置连接池，见dbutil.Connect
+	dbConn, err = dbutil.Connect("mysql", dsn)
 	if err != nil {
 		fmt.Printf("connect DB failed, err:%v\n", err)
 		return
 	}
-
-	dbConn.SetMaxOpenConns(20)
-	dbConn.SetMaxIdleConns(10)
 	return
 }
 
@@ -132,75 +130,53 @@ func namedQuery() {
 }
 
 func transactionDemo2() (err error) {
-	tx, err := dbConn.Beginx() //开启事务
-	if err != nil {
-		fmt.Printf("begin trans failed, err: %v\n", err)
-		return err
-	}
-
-	//注册  事务提交
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			panic(p) //re-throw panic after Rollback
-		} else if err != nil {
-			fmt.Println("rollback")
-			tx.Rollback()
-		} else {
-			err = tx.Commit() //err is nil, if Commit returns error, update err.
-			fmt.Println("commit")
+	//开启事务，返回错误时回滚，否则提交，见dbutil.Transaction
+	err = dbutil.Transaction(dbConn, func(tx *sqlx.Tx) error {
+		//first insert
+		sqlStr1 := "Update users set age=0 where id=?"
+		ret, err := tx.Exec(sqlStr1, 6)
+		if err != nil {
+			return err
+		}
+		n, err := ret.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return errors.New("exec sqlStr1 failed")
 		}
-	}()
-
-	//first insert
-	sqlStr1 := "Update users set age=0 where id=?"
-	ret, err := tx.Exec(sqlStr1, 6)
-	if err != nil {
-		return err
-	}
-	n, err := ret.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if n != 1 {
-		return errors.New("exec sqlStr1 failed")
-	}
 
-	//second insert
-	sqlStr2 := "Update users set age=20 where id=?"
-	ret2, err := tx.Exec(sqlStr2, 5)
-	if err != nil {
-		return err
-	}
-	n, err = ret2.RowsAffected()
+		//second insert
+		sqlStr2 := "Update users set age=20 where id=?"
+		ret2, err := tx.Exec(sqlStr2, 5)
+		if err != nil {
+			return err
+		}
+		n, err = ret2.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return errors.New("exec sqlStr2 failed")
+		}
+		return nil
+	})
 	if err != nil {
+		fmt.Println("rollback")
 		return err
 	}
-	if n != 1 {
-		return errors.New("exec sqlStr1 failed")
-	}
-	return err
+	fmt.Println("commit")
+	return nil
 }
 
-//BatchInsertUsers 自行实现批量插入，笨方法
+//BatchInsertUsers 自行实现批量插入，笨方法，拼接语句见dbutil.BatchInsert
 func BatchInsertUsers(users []*Testuser) error {
-	//存放(?,?)的slice
-	valueStrings := make([]string, 0, len(users))
-	//存放values的slice
-	valueArgs := make([]interface{}, 0, len(users)*2)
-
-	//遍历users准备相关数据
+	//存放values的slice，每个用户一行
+	rows := make([][]interface{}, 0, len(users))
 	for _, u := range users {
-		//此处占位符要与插入值的个数对应
-		valueStrings = append(valueStrings, "(?,?)")
-		valueArgs = append(valueArgs, u.Name)
-		valueArgs = append(valueArgs, u.Age)
+		rows = append(rows, []interface{}{u.Name, u.Age})
 	}
-	//自行拼接要执行的具体语句
-	sqlStr := fmt.Sprintf("insert into testuser (name,age) values %s", strings.Join(valueStrings, ","))
-	fmt.Println(sqlStr)
-	_, err := dbConn.Exec(sqlStr, valueArgs...)
-	return err
+	return dbutil.BatchInsert(dbConn, "testuser", []string{"name", "age"}, rows, 0)
 }
 
 //BatchInsertUsers2 sqlx.In批量插入
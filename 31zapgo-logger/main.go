@@ -3,9 +3,9 @@ package main
 import (
 	"net/http"
 
+	"github.com/Moqqll/02goLearning/31zapgo-logger/zaplog"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 //zap-go logger日志记录器
@@ -19,34 +19,9 @@ var logger *zap.Logger
 // 	sugalogger = logger.Sugar()
 // }
 
-//Encoder：编码器，如何写入日志，日志格式
-func getEncoder() zapcore.Encoder {
-	cfg := zap.NewProductionEncoderConfig()
-	cfg.EncodeTime = zapcore.ISO8601TimeEncoder
-	cfg.EncodeLevel = zapcore.CapitalLevelEncoder
-	return zapcore.NewJSONEncoder(cfg)
-}
-
-//WriteSyncer：指定日志将写到哪里去
-func getLogWriter() zapcore.WriteSyncer {
-	// file, _ := os.Create("./test.log")
-	lumberJackLogger := &lumberjack.Logger{
-		Filename:   "./test.log",
-		MaxSize:    1,     //单位M
-		MaxBackups: 3,     //最大切割数量，不含自身
-		MaxAge:     30,    //最大备份天数
-		Compress:   false, //是否压缩
-	}
-	return zapcore.AddSync(lumberJackLogger)
-}
-
-//自定义logger配置
+//自定义logger配置，编码器和日志文件的配置在zaplog包中，28stdlib_nethttp中的服务端也使用它
 func initLogger() {
-	encoder := getEncoder()
-	writerSyncer := getLogWriter()
-	core := zapcore.NewCore(encoder, writerSyncer, zapcore.DebugLevel)
-
-	logger = zap.New(core, zap.AddCaller())
+	logger = zaplog.New(zaplog.FileWriter("./test.log"), zapcore.DebugLevel, zap.AddCaller())
 	// sugarlogger = logger.Sugar()
 }
 
@@ -0,0 +1,36 @@
+package zaplog
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+//zap-go logger的公共配置，31zapgo-logger和28stdlib_nethttp中的服务端都用它创建logger，保证日志格式一致
+
+//Encoder：编码器，如何写入日志，日志格式
+//使用json格式，时间为ISO8601格式，日志级别大写
+func Encoder() zapcore.Encoder {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+	return zapcore.NewJSONEncoder(cfg)
+}
+
+//FileWriter WriteSyncer：日志写到filename中，用lumberjack按大小切割
+func FileWriter(filename string) zapcore.WriteSyncer {
+	lumberJackLogger := &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    1,     //单位M
+		MaxBackups: 3,     //最大切割数量，不含自身
+		MaxAge:     30,    //最大备份天数
+		Compress:   false, //是否压缩
+	}
+	return zapcore.AddSync(lumberJackLogger)
+}
+
+//New 自定义logger配置：使用Encoder编码，写到ws中，只记录level及以上级别的日志
+func New(ws zapcore.WriteSyncer, level zapcore.Level, opts ...zap.Option) *zap.Logger {
+	core := zapcore.NewCore(Encoder(), ws, level)
+	return zap.New(core, opts...)
+}
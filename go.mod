@@ -1,3 +1,11 @@
 module github.com/Moqqll/02goLearning
 
 go 1.14
+
+require (
+	github.com/Moqqll/02goLearning/31zapgo-logger v0.0.0
+	go.uber.org/zap v1.16.0
+)
+
+//31zapgo-logger是单独的module，使用本地的代码
+replace github.com/Moqqll/02goLearning/31zapgo-logger => ./31zapgo-logger